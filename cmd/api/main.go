@@ -8,11 +8,13 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the scratch image has no zoneinfo; the dashboard's ?tz needs it
 
 	"go-polyglot-persistence/internal/api"
 	"go-polyglot-persistence/internal/cache"
 	"go-polyglot-persistence/internal/config"
 	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/migrations"
 	"go-polyglot-persistence/internal/queue"
 	"go-polyglot-persistence/internal/search"
 	"go-polyglot-persistence/internal/worker"
@@ -31,6 +33,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Bulk orders are inserted here, so the API maintains the rollup too.
+	switch cfg.SalesAggregation {
+	case "refresh":
+	case "incremental":
+		db.IncrementalSales = true
+	default:
+		slog.Error("invalid sales aggregation mode", "mode", cfg.SalesAggregation)
+		os.Exit(1)
+	}
+
+	// Every replica migrates on start; the advisory lock lets one of them do
+	// the work while the others wait and then find nothing pending.
+	if cfg.MigrateOnStart {
+		migrateCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		err := migrations.Up(migrateCtx, db.Conn)
+		cancel()
+		if err != nil {
+			slog.Error("schema migration failed", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	redisClient, err := cache.New(cfg.RedisAddr)
 	if err != nil {
 		slog.Error("redis connect failed", "error", err)
@@ -49,21 +73,67 @@ func main() {
 		os.Exit(1)
 	}
 
-	// ── Background cron ────────────────────────────────────────────────────────
-
-	cronScheduler, err := worker.StartCronJobs(db, cfg.MVRefreshSchedule)
+	// Searching an index without the current mapping returns wrong results
+	// rather than errors, so refuse to start on one.
+	indexCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err = searchClient.EnsureIndex(indexCtx)
+	cancel()
 	if err != nil {
-		slog.Error("invalid cron schedule", "schedule", cfg.MVRefreshSchedule, "error", err)
+		slog.Error("elasticsearch index setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	// ── Outbox relay ───────────────────────────────────────────────────────────
+
+	// In direct mode CreateOrder publishes inline, but the relay still runs:
+	// bulk orders always go through the outbox.
+	if cfg.OrderWriteMode != "direct" && cfg.OrderWriteMode != "outbox" {
+		slog.Error("invalid order write mode", "mode", cfg.OrderWriteMode)
 		os.Exit(1)
 	}
 
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	relay := worker.NewOutboxRelay(db, publisher, cfg.OutboxMaxAttempts)
+	go relay.Run(relayCtx)
+
+	// ── Background jobs ────────────────────────────────────────────────────────
+
+	// Every replica schedules the jobs; only the elected leader runs them.
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	defer stopLeader()
+	leader := worker.NewLeaderElector(db, cfg.ReplicaID)
+
+	scheduler := worker.NewScheduler(db, leader, cfg.ReplicaID)
+	jobs := []worker.Job{
+		worker.MVRefreshJob(db, cfg.MVRefreshSchedule, cfg.MVRefreshTimeout),
+		worker.SearchReconcileJob(db, searchClient, cfg.SearchReconcileSchedule, cfg.SearchReconcileTimeout),
+		worker.RetentionJob(db, cfg.RetentionSchedule, cfg.RetentionTimeout, cfg.OutboxRetention, cfg.JobRunRetention),
+	}
+	if db.IncrementalSales {
+		jobs = append(jobs, worker.SalesReconcileJob(db, cfg.SalesReconcileSchedule, cfg.SalesReconcileTimeout))
+	}
+	for _, job := range jobs {
+		if err := scheduler.Register(job); err != nil {
+			slog.Error("invalid job configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	scheduler.Start()
+	go leader.Run(leaderCtx) // after Register, so election hooks are in place
+
 	// ── HTTP server ────────────────────────────────────────────────────────────
 
 	h := &api.Handler{
-		DB:        db,
-		Cache:     redisClient,
-		Publisher: publisher,
-		Search:    searchClient,
+		DB:          db,
+		Cache:       redisClient,
+		Publisher:   publisher,
+		Search:      searchClient,
+		Idempotency: redisClient,
+		Jobs:        scheduler,
+	}
+	if cfg.OrderWriteMode == "outbox" {
+		h.Outbox = db
 	}
 
 	mux := http.NewServeMux()
@@ -89,9 +159,12 @@ func main() {
 	//
 	// Shutdown order matters:
 	//  1. Stop accepting new HTTP requests (srv.Shutdown) — in-flight requests finish.
-	//  2. Stop the cron scheduler — waits for any running refresh to complete
+	//  2. Step down as cron leader, so no new run starts and another replica
+	//     takes over at once. job_runs keeps it from overlapping our runs.
+	//  3. Stop the job scheduler — waits for any running job to complete
 	//     before returning, so db.Close() does not yank the connection mid-query.
-	//  3. Close infrastructure clients (deferred below) in reverse init order.
+	//  4. Stop the outbox relay — waits for the in-flight batch to commit.
+	//  5. Close infrastructure clients in reverse init order.
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -105,9 +178,15 @@ func main() {
 		slog.Error("http shutdown error", "component", "api", "error", err)
 	}
 
-	// cron.Stop() blocks until the currently-running job (if any) finishes.
-	<-cronScheduler.Stop().Done()
-	slog.Info("cron stopped", "component", "api")
+	stopLeader()
+	<-leader.Done()
+
+	// scheduler.Stop() blocks until the currently-running jobs (if any) finish.
+	scheduler.Stop()
+	slog.Info("jobs stopped", "component", "api")
+
+	stopRelay()
+	<-relay.Done()
 
 	publisher.Close()
 	redisClient.Close()
@@ -0,0 +1,96 @@
+// Command migrate applies or rolls back the embedded Postgres schema migrations.
+//
+//	migrate up            apply every pending migration
+//	migrate down          roll back the most recent migration
+//	migrate to <version>  migrate up or down to exactly <version> (0 = empty schema)
+//	migrate status        list migrations and when each was applied
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"go-polyglot-persistence/internal/config"
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/migrations"
+
+	_ "github.com/lib/pq"
+)
+
+const usage = "usage: migrate up | down | status | to <version>"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+
+	db, err := database.Connect(cfg.PostgresDSN)
+	if err != nil {
+		slog.Error("postgres connect failed", "component", "migrate", "error", err)
+		os.Exit(1)
+	}
+	defer db.Conn.Close()
+
+	m, err := migrations.New(db.Conn)
+	if err != nil {
+		slog.Error("load migrations failed", "component", "migrate", "error", err)
+		os.Exit(1)
+	}
+
+	// Ctrl-C cancels the running migration's transaction, leaving the schema
+	// at the last fully applied version.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch cmd := os.Args[1]; {
+	case cmd == "up" && len(os.Args) == 2:
+		err = m.Up(ctx)
+	case cmd == "down" && len(os.Args) == 2:
+		err = m.Down(ctx)
+	case cmd == "to" && len(os.Args) == 3:
+		version, perr := strconv.ParseInt(os.Args[2], 10, 64)
+		if perr != nil || version < 0 {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n%s\n", os.Args[2], usage)
+			os.Exit(2)
+		}
+		err = m.To(ctx, version)
+	case cmd == "status" && len(os.Args) == 2:
+		err = printStatus(ctx, m)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		slog.Error("migrate failed", "component", "migrate", "command", os.Args[1], "error", err)
+		os.Exit(1)
+	}
+}
+
+func printStatus(ctx context.Context, m *migrations.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,170 @@
+// Command reindex rebuilds the Elasticsearch orders index from Postgres
+// without downtime, e.g. after a mapping or analyzer change:
+//
+//  1. create the next versioned index (orders_vN) with the current mapping
+//  2. backfill every order from Postgres into it
+//  3. catch up on orders that changed while the backfill ran
+//  4. move the read and write aliases to it in one atomic request
+//  5. catch up once more on changes the worker wrote to the old index
+//
+// Searches keep using the old index until step 4. If anything fails before
+// then, the new index is deleted and the old one stays live. The old index
+// is kept for rollback unless -delete-old is given.
+//
+//	reindex [-batch 500] [-overlap 5m] [-delete-old]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-polyglot-persistence/internal/config"
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/models"
+	"go-polyglot-persistence/internal/search"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	batch := flag.Int("batch", 500, "orders read from Postgres and bulk-indexed per request")
+	overlap := flag.Duration("overlap", 5*time.Minute,
+		"how far before each pass a catch-up starts, to cover clock skew and queue lag")
+	deleteOld := flag.Bool("delete-old", false, "delete the indices the aliases pointed at before")
+	flag.Parse()
+	if flag.NArg() > 0 || *batch < 1 || *overlap < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+
+	db, err := database.Connect(cfg.PostgresDSN)
+	if err != nil {
+		slog.Error("postgres connect failed", "component", "reindex", "error", err)
+		os.Exit(1)
+	}
+	defer db.Conn.Close()
+
+	searchClient, err := search.New(cfg.ElasticsearchURL)
+	if err != nil {
+		slog.Error("elasticsearch init failed", "component", "reindex", "error", err)
+		os.Exit(1)
+	}
+
+	// Ctrl-C before the swap abandons the new index; searches never see it.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	index, err := searchClient.CreateNextIndex(ctx)
+	if err != nil {
+		slog.Error("create index failed", "component", "reindex", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("index created", "component", "reindex", "index", index)
+
+	catchUpFrom, err := build(ctx, db, searchClient, index, *batch, *overlap)
+	if err != nil {
+		slog.Error("reindex failed; aliases unchanged", "component", "reindex", "index", index, "error", err)
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := searchClient.DeleteIndex(cleanupCtx, index); err != nil {
+			slog.Error("delete abandoned index failed", "component", "reindex", "index", index, "error", err)
+		}
+		os.Exit(1)
+	}
+
+	previous, err := searchClient.SwapAliases(ctx, index)
+	if err != nil {
+		slog.Error("alias swap failed; aliases unchanged", "component", "reindex", "index", index, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("aliases swapped", "component", "reindex", "index", index, "previous", previous)
+
+	// From here on the new index is live, so a failure leaves it in place.
+	n, err := copyOrders(ctx, db, searchClient, index, catchUpFrom, *batch)
+	if err != nil {
+		slog.Error("final catch-up failed; the new index may miss recent changes",
+			"component", "reindex", "index", index, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("final catch-up done", "component", "reindex", "index", index, "orders", n)
+
+	if *deleteOld {
+		for _, old := range previous {
+			if err := searchClient.DeleteIndex(ctx, old); err != nil {
+				slog.Error("delete old index failed", "component", "reindex", "index", old, "error", err)
+				os.Exit(1)
+			}
+			slog.Info("old index deleted", "component", "reindex", "index", old)
+		}
+	}
+}
+
+// build backfills index and catches it up, then makes it searchable. It
+// returns where the catch-up after the alias swap must start.
+func build(ctx context.Context, db *database.DB, es *search.Client, index string, batch int, overlap time.Duration) (time.Time, error) {
+	start := time.Now()
+	n, err := copyOrders(ctx, db, es, index, time.Time{}, batch)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("backfill: %w", err)
+	}
+	slog.Info("backfill done", "component", "reindex", "index", index, "orders", n, "duration", time.Since(start))
+
+	catchUp := time.Now()
+	n, err = copyOrders(ctx, db, es, index, start.Add(-overlap), batch)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("catch-up: %w", err)
+	}
+	slog.Info("catch-up done", "component", "reindex", "index", index, "orders", n)
+
+	if err := es.FinishIndex(ctx, index); err != nil {
+		return time.Time{}, err
+	}
+	return catchUp.Add(-overlap), nil
+}
+
+// copyOrders indexes every order updated at or after since (all orders for
+// the zero time) into index, batch orders per page, and returns how many it
+// copied. Documents carry the same external version as the worker's, so an
+// older copy never overwrites a newer one the worker wrote.
+func copyOrders(ctx context.Context, db *database.DB, es *search.Client, index string, since time.Time, batch int) (int, error) {
+	f := database.OrderFilter{UpdatedFrom: since, Limit: batch}
+	copied := 0
+	for {
+		orders, err := db.ListOrders(ctx, f)
+		if err != nil {
+			return copied, err
+		}
+		if len(orders) == 0 {
+			return copied, nil
+		}
+
+		page := make([]*models.Order, len(orders))
+		for i := range orders {
+			page[i] = &orders[i]
+		}
+		itemErrs, err := es.IndexOrdersInto(ctx, index, page)
+		if err != nil {
+			return copied, err
+		}
+		for i, err := range itemErrs {
+			if err != nil {
+				return copied, fmt.Errorf("order %s: %w", page[i].ID, err)
+			}
+		}
+		copied += len(orders)
+
+		if len(orders) < batch {
+			return copied, nil
+		}
+		last := orders[len(orders)-1]
+		f.After = &database.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
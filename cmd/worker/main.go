@@ -3,17 +3,22 @@ package main
 import (
 	"context"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"go-polyglot-persistence/internal/cache"
 	"go-polyglot-persistence/internal/config"
 	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/migrations"
 	"go-polyglot-persistence/internal/queue"
 	"go-polyglot-persistence/internal/search"
 	"go-polyglot-persistence/internal/worker"
 
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -27,27 +32,78 @@ func main() {
 		os.Exit(1)
 	}
 
+	switch cfg.SalesAggregation {
+	case "refresh":
+	case "incremental":
+		db.IncrementalSales = true
+	default:
+		slog.Error("invalid sales aggregation mode", "component", "worker", "mode", cfg.SalesAggregation)
+		os.Exit(1)
+	}
+
+	// Every replica migrates on start; the advisory lock lets one of them do
+	// the work while the others wait and then find nothing pending.
+	if cfg.MigrateOnStart {
+		migrateCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		err := migrations.Up(migrateCtx, db.Conn)
+		cancel()
+		if err != nil {
+			slog.Error("schema migration failed", "component", "worker", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	redisClient, err := cache.New(cfg.RedisAddr)
+	if err != nil {
+		slog.Error("redis connect failed", "component", "worker", "error", err)
+		os.Exit(1)
+	}
+
 	searchClient, err := search.New(cfg.ElasticsearchURL)
 	if err != nil {
 		slog.Error("elasticsearch init failed", "component", "worker", "error", err)
 		os.Exit(1)
 	}
 
-	consumer, err := queue.NewConsumer(cfg.RabbitMQURL)
+	indexCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err = searchClient.EnsureIndex(indexCtx)
+	cancel()
+	if err != nil {
+		slog.Error("elasticsearch index setup failed", "component", "worker", "error", err)
+		os.Exit(1)
+	}
+
+	// Every processing goroutine needs enough unacked messages to fill a batch.
+	prefetch := max(cfg.QueuePrefetch, cfg.WorkerConcurrency*cfg.WorkerBatchSize)
+
+	consumer, err := queue.NewConsumer(cfg.RabbitMQURL, prefetch, cfg.QueueMaxAttempts)
 	if err != nil {
 		slog.Error("rabbitmq connect failed", "component", "worker", "error", err)
 		os.Exit(1)
 	}
 
+	// ── Metrics ────────────────────────────────────────────────────────────────
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("GET /metrics", promhttp.Handler())
+	metricsSrv := &http.Server{Addr: ":" + cfg.WorkerMetricsPort, Handler: metricsMux}
+
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("metrics server error", "component", "worker", "error", err)
+		}
+	}()
+
 	// ── Run ────────────────────────────────────────────────────────────────────
 	//
-	// ctx is cancelled on SIGINT/SIGTERM, which causes worker.Run to drain the
-	// current in-flight message and return cleanly before we close connections.
+	// ctx is cancelled on SIGINT/SIGTERM, which causes worker.Run to flush every
+	// in-flight batch and return cleanly before we close connections.
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	w := worker.New(db, searchClient, consumer)
+	w := worker.New(db, searchClient, redisClient, consumer,
+		cfg.WorkerConcurrency, cfg.WorkerBatchSize, cfg.WorkerBatchWindow)
 	if err := w.Run(ctx); err != nil {
 		slog.Error("worker error", "component", "worker", "error", err)
 	}
@@ -57,7 +113,9 @@ func main() {
 	// Run() has returned — the consume loop is done.
 	// Close connections in reverse init order.
 
+	metricsSrv.Close()
 	consumer.Close()
+	redisClient.Close()
 	db.Conn.Close()
 
 	slog.Info("worker stopped", "component", "worker")
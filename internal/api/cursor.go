@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/search"
+)
+
+// errInvalidCursor is returned for a cursor the server did not issue.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken is the wire form of a database.OrderCursor. Clients treat the
+// encoded string as opaque, so the fields can change without breaking them.
+type cursorToken struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// encodeCursor returns the opaque next_cursor for the given keyset position.
+func encodeCursor(c database.OrderCursor) string {
+	data, _ := json.Marshal(cursorToken{CreatedAt: c.CreatedAt, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor.
+func decodeCursor(s string) (*database.OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var t cursorToken
+	if err := json.Unmarshal(data, &t); err != nil || t.ID == "" || t.CreatedAt.IsZero() {
+		return nil, errInvalidCursor
+	}
+	return &database.OrderCursor{CreatedAt: t.CreatedAt, ID: t.ID}, nil
+}
+
+// searchCursorToken is the wire form of a search.Cursor.
+type searchCursorToken struct {
+	Score     float64 `json:"s"`
+	CreatedAt int64   `json:"t"`
+	ID        string  `json:"id"`
+}
+
+// encodeSearchCursor returns the opaque next_cursor for a search page.
+func encodeSearchCursor(c search.Cursor) string {
+	data, _ := json.Marshal(searchCursorToken{Score: c.Score, CreatedAt: c.CreatedAt, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSearchCursor parses a cursor produced by encodeSearchCursor.
+func decodeSearchCursor(s string) (*search.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var t searchCursorToken
+	if err := json.Unmarshal(data, &t); err != nil || t.ID == "" {
+		return nil, errInvalidCursor
+	}
+	return &search.Cursor{Score: t.Score, CreatedAt: t.CreatedAt, ID: t.ID}, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/search"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []database.OrderCursor{
+		{CreatedAt: time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC), ID: "0b6f7c2e-0d0c-4f5e-9a51-0c3a0e2d4b11"},
+		{CreatedAt: time.Date(2025, 3, 1, 12, 30, 0, 123456789, time.UTC), ID: "a"},
+		{CreatedAt: time.Date(1999, 12, 31, 23, 59, 59, 0, time.FixedZone("", 3600)), ID: "b"},
+	}
+	for _, want := range tests {
+		got, err := decodeCursor(encodeCursor(want))
+		if err != nil {
+			t.Fatalf("decodeCursor(encodeCursor(%+v)): %v", want, err)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+			t.Errorf("round trip of %+v = %+v", want, *got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := map[string]string{
+		"not base64":      "!!!",
+		"padded base64":   base64.URLEncoding.EncodeToString([]byte(`{"t":"2025-03-01T00:00:00Z","id":"a"}`)),
+		"not JSON":        b64("cursor"),
+		"missing id":      b64(`{"t":"2025-03-01T00:00:00Z"}`),
+		"missing time":    b64(`{"id":"a"}`),
+		"wrong time type": b64(`{"t":17,"id":"a"}`),
+		"empty":           "",
+	}
+	for name, s := range tests {
+		if c, err := decodeCursor(s); err == nil {
+			t.Errorf("%s: decodeCursor(%q) = %+v, want an error", name, s, *c)
+		}
+	}
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	tests := []search.Cursor{
+		{Score: 1.2345678, CreatedAt: 1740832200000, ID: "a"},
+		{Score: 0, CreatedAt: 0, ID: "b"},
+		{Score: 13.5, CreatedAt: -1, ID: "0b6f7c2e-0d0c-4f5e-9a51-0c3a0e2d4b11"},
+	}
+	for _, want := range tests {
+		got, err := decodeSearchCursor(encodeSearchCursor(want))
+		if err != nil {
+			t.Fatalf("decodeSearchCursor(encodeSearchCursor(%+v)): %v", want, err)
+		}
+		if *got != want {
+			t.Errorf("round trip of %+v = %+v", want, *got)
+		}
+	}
+}
+
+func TestDecodeSearchCursorInvalid(t *testing.T) {
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := map[string]string{
+		"not base64":       "!!!",
+		"not JSON":         b64("cursor"),
+		"missing id":       b64(`{"s":1.5,"t":1740832200000}`),
+		"wrong score type": b64(`{"s":"high","t":1,"id":"a"}`),
+		"empty":            "",
+	}
+	for name, s := range tests {
+		if c, err := decodeSearchCursor(s); err == nil {
+			t.Errorf("%s: decodeSearchCursor(%q) = %+v, want an error", name, s, *c)
+		}
+	}
+}
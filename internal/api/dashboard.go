@@ -0,0 +1,214 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+)
+
+// maxSalesPeriods bounds one dashboard response: a year of days, or far
+// more weeks or months than anyone charts.
+const maxSalesPeriods = 366
+
+// defaultSalesPeriods is how far back the dashboard reaches without ?from.
+var defaultSalesPeriods = map[database.Granularity]int{
+	database.GranularityDay:   30,
+	database.GranularityWeek:  12,
+	database.GranularityMonth: 12,
+}
+
+// salesQuery reads the dashboard's query parameters:
+//
+//	from, to     YYYY-MM-DD dates in tz; from is inclusive, to exclusive
+//	granularity  day (default), week or month
+//	tz           IANA timezone name, default UTC
+//	currency     optional reporting currency
+//
+// from is moved back and to forward to whole periods — Monday for weeks, the
+// 1st for months — so the first and last rows are never partial. to
+// defaults to the end of the current period and from to defaultSalesPeriods
+// before it. now is the request time.
+func (v *validator) salesQuery(q url.Values, now time.Time) database.SalesQuery {
+	sq := database.SalesQuery{
+		Granularity: database.Granularity(q.Get("granularity")),
+		TZ:          q.Get("tz"),
+		Currency:    q.Get("currency"),
+	}
+	if sq.Granularity == "" {
+		sq.Granularity = database.GranularityDay
+	}
+	if !sq.Granularity.Valid() {
+		v.add("granularity", `must be "day", "week" or "month"`)
+	}
+	if sq.TZ == "" {
+		sq.TZ = "UTC"
+	}
+	// "Local" would mean the server's zone, which clients cannot know.
+	loc, err := time.LoadLocation(sq.TZ)
+	if err != nil || sq.TZ == "Local" {
+		v.add("tz", "must be an IANA timezone name such as Europe/Berlin")
+	}
+	v.currency("currency", sq.Currency, false)
+
+	from, fromErr := parseOptionalDate(q.Get("from"))
+	if fromErr != nil {
+		v.add("from", "must be a date in YYYY-MM-DD form")
+	}
+	to, toErr := parseOptionalDate(q.Get("to"))
+	if toErr != nil {
+		v.add("to", "must be a date in YYYY-MM-DD form")
+	}
+	if !v.ok() {
+		return sq
+	}
+
+	g := sq.Granularity
+	if to.IsZero() {
+		local := now.In(loc)
+		to = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	to = addPeriods(periodStart(to.AddDate(0, 0, -1), g), g, 1)
+	if from.IsZero() {
+		from = addPeriods(to, g, -defaultSalesPeriods[g])
+	}
+	from = periodStart(from, g)
+
+	n := 0
+	for p := from; p.Before(to) && n <= maxSalesPeriods; p = addPeriods(p, g, 1) {
+		n++
+	}
+	switch {
+	case !from.Before(to):
+		v.add("from", "must be before to")
+	case n > maxSalesPeriods:
+		v.add("from", "must be at most %d %ss before to", maxSalesPeriods, g)
+	}
+
+	sq.From, sq.To = from.Format(time.DateOnly), to.Format(time.DateOnly)
+	return sq
+}
+
+// parseOptionalDate parses a YYYY-MM-DD date as midnight UTC, or returns the
+// zero time for an empty string. Dashboard dates are calendar days in the
+// request's timezone; UTC only makes date arithmetic free of DST shifts.
+func parseOptionalDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// periodStart returns the first day of the period containing d.
+func periodStart(d time.Time, g database.Granularity) time.Time {
+	switch g {
+	case database.GranularityWeek:
+		return d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+	case database.GranularityMonth:
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return d
+}
+
+// addPeriods moves d, which must start a period, n periods forward.
+func addPeriods(d time.Time, g database.Granularity, n int) time.Time {
+	switch g {
+	case database.GranularityWeek:
+		return d.AddDate(0, 0, 7*n)
+	case database.GranularityMonth:
+		return d.AddDate(0, n, 0)
+	}
+	return d.AddDate(0, 0, n)
+}
+
+// Product dashboard limits. Product rows are kept per UTC day, so these
+// endpoints take UTC dates and have no tz.
+const (
+	defaultProductDays = 30
+	maxProductDays     = 366
+	maxProductRows     = 10_000 // GET /api/dashboard/products
+	defaultTopProducts = 10
+	maxTopProducts     = 100
+)
+
+// productSalesQuery reads the GET /api/dashboard/products parameters:
+// from (inclusive) and to (exclusive) as YYYY-MM-DD UTC dates, defaulting to
+// the defaultProductDays up to and including today, and the optional sku,
+// product and currency filters.
+func (v *validator) productSalesQuery(q url.Values, now time.Time) database.ProductSalesQuery {
+	query := database.ProductSalesQuery{
+		SKU:         q.Get("sku"),
+		ProductName: q.Get("product"),
+		Currency:    q.Get("currency"),
+		Limit:       maxProductRows,
+	}
+	v.currency("currency", query.Currency, false)
+
+	from, err := parseOptionalDate(q.Get("from"))
+	if err != nil {
+		v.add("from", "must be a date in YYYY-MM-DD form")
+	}
+	to, err := parseOptionalDate(q.Get("to"))
+	if err != nil {
+		v.add("to", "must be a date in YYYY-MM-DD form")
+	}
+	if !v.ok() {
+		return query
+	}
+
+	if to.IsZero() {
+		to = utcDate(now).AddDate(0, 0, 1)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultProductDays)
+	}
+	switch {
+	case !from.Before(to):
+		v.add("from", "must be before to")
+	case to.Sub(from) > maxProductDays*24*time.Hour:
+		v.add("from", "must be at most %d days before to", maxProductDays)
+	}
+
+	query.From, query.To = from.Format(time.DateOnly), to.Format(time.DateOnly)
+	return query
+}
+
+// topProductsQuery reads the GET /api/dashboard/top-products parameters:
+// n, the number of products per currency, and period, a trailing window of
+// whole UTC days such as 7d that ends with today. currency optionally limits
+// the ranking to one currency.
+func (v *validator) topProductsQuery(q url.Values, now time.Time) database.ProductSalesQuery {
+	query := database.ProductSalesQuery{
+		Currency: q.Get("currency"),
+		Limit:    defaultTopProducts,
+	}
+	v.currency("currency", query.Currency, false)
+
+	if s := q.Get("n"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxTopProducts {
+			v.add("n", "must be an integer from 1 to %d", maxTopProducts)
+		}
+		query.Limit = n
+	}
+
+	days := defaultProductDays
+	if s := q.Get("period"); s != "" {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || !strings.HasSuffix(s, "d") || n < 1 || n > maxProductDays {
+			v.add("period", "must be a number of days from 1d to %dd", maxProductDays)
+		}
+		days = n
+	}
+
+	to := utcDate(now).AddDate(0, 0, 1)
+	query.From, query.To = to.AddDate(0, 0, -days).Format(time.DateOnly), to.Format(time.DateOnly)
+	return query
+}
+
+// utcDate returns midnight UTC of t's UTC day.
+func utcDate(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
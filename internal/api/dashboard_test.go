@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+)
+
+func TestSalesQuery(t *testing.T) {
+	// A Wednesday evening in UTC, already Thursday in Tokyo.
+	now := time.Date(2025, 3, 12, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    string
+		from, to string
+		tz       string
+	}{
+		{name: "defaults", query: "", from: "2025-02-11", to: "2025-03-13", tz: "UTC"},
+		{name: "today in tz", query: "tz=Asia/Tokyo", from: "2025-02-12", to: "2025-03-14", tz: "Asia/Tokyo"},
+		{name: "yesterday in tz", query: "tz=America/Los_Angeles", from: "2025-02-11", to: "2025-03-13", tz: "America/Los_Angeles"},
+		{name: "default weeks", query: "granularity=week", from: "2024-12-23", to: "2025-03-17", tz: "UTC"},
+		{name: "default months", query: "granularity=month", from: "2024-04-01", to: "2025-04-01", tz: "UTC"},
+		{name: "explicit days", query: "from=2025-01-01&to=2025-01-08", from: "2025-01-01", to: "2025-01-08", tz: "UTC"},
+		{name: "weeks widened to Monday", query: "granularity=week&from=2025-03-05&to=2025-03-06", from: "2025-03-03", to: "2025-03-10", tz: "UTC"},
+		{name: "to on a Monday", query: "granularity=week&from=2025-03-03&to=2025-03-10", from: "2025-03-03", to: "2025-03-10", tz: "UTC"},
+		{name: "months widened", query: "granularity=month&from=2025-01-15&to=2025-02-02", from: "2025-01-01", to: "2025-03-01", tz: "UTC"},
+		{name: "from defaults before to", query: "granularity=month&to=2025-01-01", from: "2024-01-01", to: "2025-01-01", tz: "UTC"},
+		{name: "366 days", query: "from=2024-01-01&to=2025-01-01", from: "2024-01-01", to: "2025-01-01", tz: "UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v validator
+			got := v.salesQuery(q, now)
+			if !v.ok() {
+				t.Fatalf("errors: %+v", v.errs)
+			}
+			if got.From != tt.from || got.To != tt.to || got.TZ != tt.tz {
+				t.Errorf("got from=%s to=%s tz=%s, want from=%s to=%s tz=%s",
+					got.From, got.To, got.TZ, tt.from, tt.to, tt.tz)
+			}
+		})
+	}
+}
+
+func TestSalesQueryInvalid(t *testing.T) {
+	now := time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query  string
+		fields []string
+	}{
+		{query: "granularity=year", fields: []string{"granularity"}},
+		{query: "tz=Mars/Olympus_Mons", fields: []string{"tz"}},
+		{query: "tz=Local", fields: []string{"tz"}},
+		{query: "currency=XXX", fields: []string{"currency"}},
+		{query: "from=2025-13-01&to=01/02/2025", fields: []string{"from", "to"}},
+		{query: "from=2025-03-10&to=2025-03-10", fields: []string{"from"}},
+		{query: "from=2025-03-11&to=2025-03-10", fields: []string{"from"}},
+		{query: "from=2023-12-31&to=2025-01-01", fields: []string{"from"}},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v validator
+		v.salesQuery(q, now)
+		var fields []string
+		for _, e := range v.errs {
+			fields = append(fields, e.Field)
+		}
+		if !slices.Equal(fields, tt.fields) {
+			t.Errorf("%s: errors on %v, want %v", tt.query, fields, tt.fields)
+		}
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		day  string
+		g    database.Granularity
+		want string
+		next string
+	}{
+		{"2025-03-12", database.GranularityDay, "2025-03-12", "2025-03-13"},
+		{"2025-03-12", database.GranularityWeek, "2025-03-10", "2025-03-17"},
+		{"2025-03-10", database.GranularityWeek, "2025-03-10", "2025-03-17"},
+		{"2025-03-16", database.GranularityWeek, "2025-03-10", "2025-03-17"},
+		{"2025-01-01", database.GranularityWeek, "2024-12-30", "2025-01-06"},
+		{"2025-03-31", database.GranularityMonth, "2025-03-01", "2025-04-01"},
+		{"2024-12-15", database.GranularityMonth, "2024-12-01", "2025-01-01"},
+		{"2024-02-29", database.GranularityMonth, "2024-02-01", "2024-03-01"},
+	}
+	for _, tt := range tests {
+		start := periodStart(date(tt.day), tt.g)
+		if got := start.Format(time.DateOnly); got != tt.want {
+			t.Errorf("periodStart(%s, %s) = %s, want %s", tt.day, tt.g, got, tt.want)
+		}
+		if got := addPeriods(start, tt.g, 1).Format(time.DateOnly); got != tt.next {
+			t.Errorf("addPeriods(%s, %s, 1) = %s, want %s", tt.want, tt.g, got, tt.next)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package api
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"go-polyglot-persistence/internal/database"
 	"go-polyglot-persistence/internal/models"
+	"go-polyglot-persistence/internal/search"
+	"go-polyglot-persistence/internal/worker"
 
 	"github.com/google/uuid"
 )
@@ -32,11 +40,26 @@ type OrderCache interface {
 // OrderQueue is the publish contract for the message broker.
 type OrderQueue interface {
 	PublishOrder(ctx context.Context, order *models.Order) error
+	PublishStatusChange(ctx context.Context, change *models.StatusChange) error
+}
+
+// OrderOutbox is the transactional outbox contract.
+// An order accepted by EnqueueOrder is durably committed and will be
+// published to the queue by a relay.
+type OrderOutbox interface {
+	EnqueueOrder(ctx context.Context, order *models.Order) error
 }
 
 // OrderSearch is the full-text search contract.
 type OrderSearch interface {
-	SearchOrders(ctx context.Context, term string) (json.RawMessage, error)
+	SearchOrders(ctx context.Context, q search.OrderQuery) (*search.SearchResult, error)
+	SuggestProducts(ctx context.Context, prefix string, limit int) ([]search.ProductSuggestion, error)
+}
+
+// JobScheduler is the background job registry contract.
+type JobScheduler interface {
+	Jobs(ctx context.Context) ([]worker.JobStatus, error)
+	Trigger(name string) (*database.JobRun, error)
 }
 
 // ---------------------------------------------------------------------------
@@ -50,32 +73,129 @@ type Handler struct {
 	DB        *database.DB // owns SQL; stays concrete because it also drives the cron
 	Cache     OrderCache
 	Publisher OrderQueue
+	Outbox    OrderOutbox // optional; when set, CreateOrder uses the outbox instead of Publisher
 	Search    OrderSearch
+
+	Idempotency IdempotencyStore // optional; when set, order-creating POSTs honour Idempotency-Key
+	Jobs        JobScheduler
 }
 
 // ---------------------------------------------------------------------------
 // Orders
 // ---------------------------------------------------------------------------
 
+// createOrderRequest is the POST /api/orders body. It is deliberately not
+// models.Order: server-owned fields such as id, status and created_at are
+// unknown fields here and are rejected rather than silently overwritten.
+type createOrderRequest struct {
+	Currency string             `json:"currency"`
+	Items    []orderItemRequest `json:"items"`
+}
+
+// orderItemRequest is one line of a createOrderRequest. UnitPrice is kept
+// as the literal JSON number so it is parsed exactly, never via float64.
+type orderItemRequest struct {
+	SKU         string      `json:"sku"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   json.Number `json:"unit_price"`
+}
+
+// validate records every problem with req, naming fields under prefix
+// (e.g. "orders[3]." in a bulk request). On success it returns the order's
+// items with parsed prices.
+func (req *createOrderRequest) validate(v *validator, prefix string) []models.OrderItem {
+	currency := cmp.Or(req.Currency, models.DefaultCurrency)
+	v.currency(prefix+"currency", req.Currency, false)
+	decimals, ok := models.CurrencyDecimals(currency)
+	if !ok {
+		decimals = 2
+	}
+
+	switch {
+	case len(req.Items) == 0:
+		v.add(prefix+"items", "must contain at least one item")
+		return nil
+	case len(req.Items) > maxOrderItems:
+		v.add(prefix+"items", "must contain at most %d items", maxOrderItems)
+		return nil
+	}
+
+	items := make([]models.OrderItem, len(req.Items))
+	var total models.Money
+	for i, item := range req.Items {
+		field := fmt.Sprintf("%sitems[%d].", prefix, i)
+		v.sku(field+"sku", item.SKU)
+		v.productName(field+"product_name", item.ProductName)
+		v.quantity(field+"quantity", item.Quantity)
+		items[i] = models.OrderItem{
+			SKU:         item.SKU,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			UnitPrice:   v.amount(field+"unit_price", item.UnitPrice.String()),
+		}
+		if !items[i].UnitPrice.HasDecimals(decimals) {
+			v.add(field+"unit_price", "must have at most %d decimal places in %s", decimals, currency)
+		}
+		total += items[i].UnitPrice.Mul(item.Quantity)
+	}
+	if total > maxAmount {
+		v.add(prefix+"items", "order total must be at most %s", maxAmount)
+	}
+	return items
+}
+
+// newOrder builds a pending order from validated items. The total is
+// computed from the items; clients never supply it.
+func newOrder(currency string, items []models.OrderItem, now time.Time) *models.Order {
+	order := &models.Order{
+		ID:        uuid.New().String(),
+		Items:     items,
+		Currency:  cmp.Or(currency, models.DefaultCurrency),
+		Status:    models.StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	order.Amount = order.Total()
+	return order
+}
+
 // CreateOrder — POST /api/orders
 //
+// The body is validated first (see validation.go); failures are 400/413/422
+// problem+json responses with a per-field error list.
+//
 // Write-back path:
 //  1. Assign UUID + timestamp.
 //  2. Cache in Redis immediately so a GET can return before the worker runs.
 //  3. Publish to RabbitMQ — worker persists to Postgres + ES asynchronously.
 //  4. Return 202 Accepted; caller never waits for a DB write.
+//
+// Outbox path (Handler.Outbox set):
+//  1. Assign UUID + timestamp.
+//  2. Commit the order to the Postgres outbox — the only write that can fail the request.
+//  3. Cache in Redis, now that the order is guaranteed to be published.
+//  4. Return 202 Accepted; the outbox relay publishes to RabbitMQ.
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	var order models.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+	var req createOrderRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var v validator
+	items := req.validate(&v, "")
+	if v.writeIfInvalid(w, r) {
 		return
 	}
 
-	order.ID = uuid.New().String()
-	order.CreatedAt = time.Now().UTC()
+	order := newOrder(req.Currency, items, time.Now().UTC())
 	ctx := r.Context()
 
-	if err := h.Cache.SetOrder(ctx, &order); err != nil {
+	if h.Outbox != nil {
+		h.createOrderViaOutbox(w, r, order)
+		return
+	}
+
+	if err := h.Cache.SetOrder(ctx, order); err != nil {
 		// Non-fatal: the message still enters the queue and will be persisted.
 		slog.Error("cache write failed",
 			"component", "api",
@@ -84,26 +204,67 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 
-	if err := h.Publisher.PublishOrder(ctx, &order); err != nil {
+	if err := h.Publisher.PublishOrder(ctx, order); err != nil {
 		slog.Error("queue publish failed",
 			"component", "api",
 			"order_id", order.ID,
 			"error", err,
 		)
-		http.Error(w, "failed to enqueue order", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to enqueue order")
+		return
+	}
+
+	slog.Info("order accepted",
+		"component", "api",
+		"order_id", order.ID,
+		"items", len(order.Items),
+		"amount", order.Amount,
+	)
+	writeAccepted(w, order.ID)
+}
+
+// createOrderViaOutbox is the outbox variant of CreateOrder.
+// Nothing is cached until the outbox row commits, so a failed request
+// never leaves a phantom order in Redis.
+func (h *Handler) createOrderViaOutbox(w http.ResponseWriter, r *http.Request, order *models.Order) {
+	ctx := r.Context()
+
+	if err := h.Outbox.EnqueueOrder(ctx, order); err != nil {
+		slog.Error("outbox write failed",
+			"component", "api",
+			"order_id", order.ID,
+			"error", err,
+		)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to enqueue order")
 		return
 	}
 
+	if err := h.Cache.SetOrder(ctx, order); err != nil {
+		// Non-fatal: the order is committed and GET falls back to Postgres.
+		slog.Error("cache write failed",
+			"component", "api",
+			"order_id", order.ID,
+			"error", err,
+		)
+	}
+
 	slog.Info("order accepted",
 		"component", "api",
 		"order_id", order.ID,
-		"product", order.ProductName,
+		"items", len(order.Items),
+		"amount", order.Amount,
+		"mode", "outbox",
 	)
+	writeAccepted(w, order.ID)
+}
+
+// writeAccepted sends the 202 body shared by both CreateOrder paths.
+func writeAccepted(w http.ResponseWriter, orderID string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":   "processing",
-		"order_id": order.ID,
+		"order_id": orderID,
 	})
 }
 
@@ -117,7 +278,7 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	orderID := strings.TrimPrefix(r.URL.Path, "/api/orders/")
 	if orderID == "" {
-		http.Error(w, "missing order ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "missing order ID")
 		return
 	}
 	ctx := r.Context()
@@ -133,7 +294,7 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	// Cache MISS → Postgres
 	order, err := h.DB.GetOrderByID(ctx, orderID)
 	if errors.Is(err, sql.ErrNoRows) {
-		http.Error(w, "order not found", http.StatusNotFound)
+		writeProblem(w, r, http.StatusNotFound, "order not found")
 		return
 	}
 	if err != nil {
@@ -142,7 +303,7 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 			"order_id", orderID,
 			"error", err,
 		)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "")
 		return
 	}
 
@@ -153,99 +314,833 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(order)
 }
 
+// UpdateOrderStatus — PATCH /api/orders/{id}
+//
+// Body: {"status": "confirmed" | "cancelled" | "refunded"}
+//
+// Status changes go through the queue like creations, so the worker is the
+// only writer and Postgres, ES and Redis converge. The transition is checked
+// here against the order's current status for a fast 409, and again by the
+// worker under a row lock, which is authoritative.
+func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status models.OrderStatus `json:"status"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !req.Status.Valid() || req.Status == models.StatusPending {
+		writeProblem(w, r, http.StatusUnprocessableEntity, "request failed validation",
+			FieldError{Field: "status", Message: `must be "confirmed", "cancelled" or "refunded"`})
+		return
+	}
+
+	h.changeStatus(w, r, r.PathValue("id"), req.Status)
+}
+
+// CancelOrder — POST /api/orders/{id}/cancel
+//
+// Shorthand for PATCH /api/orders/{id} with {"status": "cancelled"}.
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, r.PathValue("id"), models.StatusCancelled)
+}
+
+// changeStatus validates a transition against the current order and
+// publishes it for the worker. Responds 202 once the broker has confirmed it.
+func (h *Handler) changeStatus(w http.ResponseWriter, r *http.Request, orderID string, to models.OrderStatus) {
+	ctx := r.Context()
+
+	order, err := h.currentOrder(ctx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeProblem(w, r, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		slog.Error("postgres read failed",
+			"component", "api",
+			"order_id", orderID,
+			"error", err,
+		)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	if order.Status != to && !order.Status.CanTransitionTo(to) {
+		writeProblem(w, r, http.StatusConflict, fmt.Sprintf("cannot change status from %s to %s", order.Status, to))
+		return
+	}
+
+	change := &models.StatusChange{
+		OrderID:     orderID,
+		Status:      to,
+		RequestedAt: time.Now().UTC(),
+	}
+	if err := h.Publisher.PublishStatusChange(ctx, change); err != nil {
+		slog.Error("queue publish failed",
+			"component", "api",
+			"order_id", orderID,
+			"error", err,
+		)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to enqueue status change")
+		return
+	}
+
+	slog.Info("status change accepted",
+		"component", "api",
+		"order_id", orderID,
+		"from", order.Status,
+		"to", to,
+	)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":           "processing",
+		"order_id":         orderID,
+		"requested_status": string(to),
+	})
+}
+
+// currentOrder reads an order cache-first, falling back to Postgres.
+// Returns sql.ErrNoRows when the order does not exist in either.
+func (h *Handler) currentOrder(ctx context.Context, id string) (*models.Order, error) {
+	if order, err := h.Cache.GetOrder(ctx, id); err == nil {
+		return order, nil
+	}
+	return h.DB.GetOrderByID(ctx, id)
+}
+
+// Page size bounds for GET /api/orders and GET /api/search.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListOrders — GET /api/orders
+//
+// Query parameters (all optional):
+//
+//	product        orders with an item of this exact product_name
+//	sku            orders with an item of this SKU
+//	status         pending | confirmed | cancelled | refunded
+//	currency       ISO 4217 code of the orders
+//	min_amount     inclusive lower bound on the order total; requires currency
+//	max_amount     inclusive upper bound on the order total; requires currency
+//	created_from   RFC 3339, inclusive
+//	created_to     RFC 3339, exclusive
+//	sort           "desc" (newest first, default) or "asc"
+//	limit          page size, 1..100 (default 20)
+//	cursor         next_cursor from the previous page
+//
+// Reads go straight to Postgres — the cache only holds single orders by ID.
+// next_cursor is omitted on the last page.
+func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := database.OrderFilter{
+		ProductName: q.Get("product"),
+		SKU:         q.Get("sku"),
+		Status:      models.OrderStatus(q.Get("status")),
+		Currency:    q.Get("currency"),
+		Descending:  true,
+		Limit:       defaultPageSize,
+	}
+
+	var (
+		v   validator
+		err error
+	)
+	if filter.Status != "" && !filter.Status.Valid() {
+		v.add("status", "must be one of pending, confirmed, cancelled, refunded")
+	}
+	v.currency("currency", filter.Currency, false)
+	if filter.MinAmount, err = parseOptionalMoney(q.Get("min_amount")); err != nil {
+		v.add("min_amount", "must be a decimal amount with at most 2 decimal places")
+	}
+	if filter.MaxAmount, err = parseOptionalMoney(q.Get("max_amount")); err != nil {
+		v.add("max_amount", "must be a decimal amount with at most 2 decimal places")
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		v.add("max_amount", "must not be less than min_amount")
+	}
+	// Totals in different currencies are not comparable.
+	if (filter.MinAmount != nil || filter.MaxAmount != nil) && filter.Currency == "" {
+		v.add("currency", "is required with min_amount or max_amount")
+	}
+	if filter.CreatedFrom, err = parseOptionalTime(q.Get("created_from")); err != nil {
+		v.add("created_from", "must be an RFC 3339 timestamp")
+	}
+	if filter.CreatedTo, err = parseOptionalTime(q.Get("created_to")); err != nil {
+		v.add("created_to", "must be an RFC 3339 timestamp")
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		v.add("created_to", "must be after created_from")
+	}
+
+	switch q.Get("sort") {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		v.add("sort", `must be "asc" or "desc"`)
+	}
+
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			v.add("limit", "must be an integer from 1 to %d", maxPageSize)
+		}
+		filter.Limit = n
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		if filter.After, err = decodeCursor(s); err != nil {
+			v.add("cursor", "is not a cursor issued by this API")
+		}
+	}
+
+	if !v.ok() {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", v.errs...)
+		return
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	pageSize := filter.Limit
+	filter.Limit++
+
+	orders, err := h.DB.ListOrders(r.Context(), filter)
+	if err != nil {
+		slog.Error("postgres list failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	resp := struct {
+		Orders     []models.Order `json:"orders"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+	}{Orders: orders}
+
+	if len(orders) > pageSize {
+		resp.Orders = orders[:pageSize]
+		last := resp.Orders[pageSize-1]
+		resp.NextCursor = encodeCursor(database.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseOptionalMoney returns nil for an empty parameter.
+func parseOptionalMoney(v string) (*models.Money, error) {
+	if v == "" {
+		return nil, nil
+	}
+	m, err := models.ParseMoney(v)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// parseOptionalTime returns the zero time for an empty parameter.
+func parseOptionalTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
 // ---------------------------------------------------------------------------
 // Search
 // ---------------------------------------------------------------------------
 
-// SearchOrders — GET /api/search?q={term}
+// SearchOrders — GET /api/search[?q=&product=&currency=&min_amount=&max_amount=&created_from=&created_to=&limit=&cursor=&facets=]
+//
+// Searches orders in Elasticsearch, most relevant first, then newest first.
+// Query parameters:
 //
-// Proxies a full-text match on product_name to Elasticsearch.
+//	q                 full-text match on item product names, or an exact SKU
+//	product           exact product name of at least one item
+//	currency          ISO 4217 code of the orders
+//	min_amount        inclusive lower bound on the order total; requires currency
+//	max_amount        inclusive upper bound on the order total; requires currency
+//	created_from      RFC 3339, inclusive
+//	created_to        RFC 3339, exclusive
+//	limit             page size, 1..100 (default 20)
+//	cursor            next_cursor from the previous page
+//	facets            comma-separated breakdowns: products, amount, created
+//	amount_interval   width of an amount facet bucket (default 100)
+//	created_interval  day (default), week or month, for the created facet
+//
+// Without q every order passing the filters matches. The response is
+// {"total": n, "orders": [...], "next_cursor": "...", "facets": {...}};
+// total counts every match and next_cursor is omitted on the last page.
+// facets is present only when requested, and covers every match, so a
+// client asks for it on the first page only.
 func (h *Handler) SearchOrders(w http.ResponseWriter, r *http.Request) {
-	term := r.URL.Query().Get("q")
-	if term == "" {
-		http.Error(w, "missing required query parameter: q", http.StatusBadRequest)
+	var v validator
+	query := v.orderQuery(r.URL.Query())
+	if !v.ok() {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", v.errs...)
 		return
 	}
 
-	result, err := h.Search.SearchOrders(r.Context(), term)
+	result, err := h.Search.SearchOrders(r.Context(), query)
 	if err != nil {
 		slog.Error("elasticsearch search failed",
 			"component", "api",
-			"term", term,
+			"term", query.Term,
 			"error", err,
 		)
-		http.Error(w, "search engine error", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "search engine error")
 		return
 	}
 
+	resp := struct {
+		Total      int            `json:"total"`
+		Orders     []models.Order `json:"orders"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+		Facets     *search.Facets `json:"facets,omitempty"`
+	}{Total: result.Total, Orders: result.Orders, Facets: result.Facets}
+	if result.Next != nil {
+		resp.NextCursor = encodeSearchCursor(*result.Next)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(result)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// orderQuery reads the GET /api/search parameters into a search query,
+// recording every invalid one.
+func (v *validator) orderQuery(q url.Values) search.OrderQuery {
+	query := search.OrderQuery{
+		Term:            strings.TrimSpace(q.Get("q")),
+		Product:         q.Get("product"),
+		Currency:        q.Get("currency"),
+		Size:            defaultPageSize,
+		AmountInterval:  defaultAmountInterval,
+		CreatedInterval: "day",
+	}
+
+	var err error
+	v.currency("currency", query.Currency, false)
+	if query.MinAmount, err = parseOptionalMoney(q.Get("min_amount")); err != nil {
+		v.add("min_amount", "must be a decimal amount with at most 2 decimal places")
+	}
+	if query.MaxAmount, err = parseOptionalMoney(q.Get("max_amount")); err != nil {
+		v.add("max_amount", "must be a decimal amount with at most 2 decimal places")
+	}
+	if query.MinAmount != nil && query.MaxAmount != nil && *query.MinAmount > *query.MaxAmount {
+		v.add("max_amount", "must not be less than min_amount")
+	}
+	// Totals in different currencies are not comparable.
+	if (query.MinAmount != nil || query.MaxAmount != nil) && query.Currency == "" {
+		v.add("currency", "is required with min_amount or max_amount")
+	}
+	if query.CreatedFrom, err = parseOptionalTime(q.Get("created_from")); err != nil {
+		v.add("created_from", "must be an RFC 3339 timestamp")
+	}
+	if query.CreatedTo, err = parseOptionalTime(q.Get("created_to")); err != nil {
+		v.add("created_to", "must be an RFC 3339 timestamp")
+	}
+	if !query.CreatedFrom.IsZero() && !query.CreatedTo.IsZero() && !query.CreatedFrom.Before(query.CreatedTo) {
+		v.add("created_to", "must be after created_from")
+	}
+
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			v.add("limit", "must be an integer from 1 to %d", maxPageSize)
+		}
+		query.Size = n
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		if query.After, err = decodeSearchCursor(s); err != nil {
+			v.add("cursor", "is not a cursor issued by this API")
+		}
+	}
+
+	if s := q.Get("facets"); s != "" {
+		for name := range strings.SplitSeq(s, ",") {
+			f := search.Facet(strings.TrimSpace(name))
+			if !f.Valid() {
+				v.add("facets", "must be a comma-separated list of products, amount, created")
+				break
+			}
+			if !slices.Contains(query.Facets, f) {
+				query.Facets = append(query.Facets, f)
+			}
+		}
+	}
+	if s := q.Get("amount_interval"); s != "" {
+		m, err := models.ParseMoney(s)
+		if err != nil || m <= 0 {
+			v.add("amount_interval", "must be a positive decimal amount with at most 2 decimal places")
+		}
+		query.AmountInterval = m
+	}
+	switch s := q.Get("created_interval"); s {
+	case "":
+	case "day", "week", "month":
+		query.CreatedInterval = s
+	default:
+		v.add("created_interval", `must be "day", "week" or "month"`)
+	}
+	return query
+}
+
+// defaultAmountInterval is the amount facet's bucket width: 100.00.
+const defaultAmountInterval models.Money = 100_00
+
+// Bounds for GET /api/search/suggest. A typeahead needs a handful of
+// suggestions, and a prefix longer than any product name matches nothing.
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 20
+	maxPrefixLength    = 100
+)
+
+// SuggestProducts — GET /api/search/suggest?prefix={text}[&limit=]
+//
+// Autocompletes product names for a storefront typeahead. Every word of
+// prefix must start a word of the name, case and accents ignored, so "gam
+// lap" suggests "Gaming Laptop". Each name appears once, with the number of
+// orders containing it, most ordered first; limit is 1..20 (default 10).
+func (h *Handler) SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := strings.TrimSpace(q.Get("prefix"))
+	limit := defaultSuggestions
+
+	var v validator
+	switch {
+	case prefix == "":
+		v.add("prefix", "is required")
+	case utf8.RuneCountInString(prefix) > maxPrefixLength:
+		v.add("prefix", "must be at most %d characters", maxPrefixLength)
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxSuggestions {
+			v.add("limit", "must be an integer from 1 to %d", maxSuggestions)
+		}
+		limit = n
+	}
+	if !v.ok() {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", v.errs...)
+		return
+	}
+
+	suggestions, err := h.Search.SuggestProducts(r.Context(), prefix, limit)
+	if err != nil {
+		slog.Error("elasticsearch suggest failed",
+			"component", "api",
+			"prefix", prefix,
+			"error", err,
+		)
+		writeProblem(w, r, http.StatusInternalServerError, "search engine error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"suggestions": suggestions})
 }
 
 // ---------------------------------------------------------------------------
 // Dashboard
 // ---------------------------------------------------------------------------
 
-// GetSalesDashboard — GET /api/dashboard/sales
+// GetSalesDashboard — GET /api/dashboard/sales[?from=&to=&granularity=&tz=&currency=]
+//
+// Returns revenue, units sold and order count per day, week or month of the
+// tz timezone, newest first, regrouped from the 15-minute buckets of
+// sales_quarter_hour_mv. Reads are fast: the join over line items runs at
+// refresh time, not at query time. Parameters are described on salesQuery;
+// without any the response is the last 30 UTC days.
 //
-// Returns the last 30 days of pre-aggregated daily revenue from daily_sales_mv.
-// Reads are fast: the GROUP BY runs at refresh time, not at query time.
+// Without currency there is one row per period and currency. With it, each
+// day's sales are converted into that currency at the rate effective on the
+// day and summed into one row per period; a missing rate is a 422 naming
+// every gap.
 func (h *Handler) GetSalesDashboard(w http.ResponseWriter, r *http.Request) {
-	sales, err := h.DB.GetSales(r.Context())
+	var v validator
+	query := v.salesQuery(r.URL.Query(), time.Now())
+	if !v.ok() {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", v.errs...)
+		return
+	}
+	currency := query.Currency
+
+	sales, err := h.DB.GetSales(r.Context(), query)
+	var missing *database.MissingRatesError
+	if errors.As(err, &missing) {
+		gaps := make([]FieldError, len(missing.Missing))
+		for i, m := range missing.Missing {
+			gaps[i] = FieldError{
+				Field:   "currency",
+				Message: fmt.Sprintf("no %s→%s rate effective on %s", m.Currency, currency, m.Date),
+			}
+		}
+		writeProblem(w, r, http.StatusUnprocessableEntity, "exchange rates missing for the reporting currency", gaps...)
+		return
+	}
 	if err != nil {
 		slog.Error("dashboard query failed",
 			"component", "api",
 			"client_ip", r.RemoteAddr,
 			"error", err,
 		)
-		http.Error(w, "failed to fetch dashboard data", http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to fetch dashboard data")
+		return
+	}
+
+	slog.Info("dashboard fetched",
+		"component", "api",
+		"granularity", query.Granularity,
+		"tz", query.TZ,
+		"records", len(sales),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sales)
+}
+
+// GetProductsDashboard — GET /api/dashboard/products[?from=&to=&sku=&product=&currency=]
+//
+// Returns revenue, units sold, order count and average order value per UTC
+// day, product and currency from product_sales_daily_mv, newest day first.
+// A range holding more than maxProductRows rows is a 422 rather than a
+// silently truncated answer; narrowing the range or filtering fixes it.
+func (h *Handler) GetProductsDashboard(w http.ResponseWriter, r *http.Request) {
+	var v validator
+	query := v.productSalesQuery(r.URL.Query(), time.Now())
+	if !v.ok() {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", v.errs...)
+		return
+	}
+
+	query.Limit++ // one extra row tells us the range is too large
+	sales, err := h.DB.GetProductSales(r.Context(), query)
+	if err != nil {
+		slog.Error("product dashboard query failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to fetch dashboard data")
+		return
+	}
+	if len(sales) > maxProductRows {
+		writeProblem(w, r, http.StatusUnprocessableEntity, "too many rows for one response",
+			FieldError{Field: "from", Message: fmt.Sprintf(
+				"range holds more than %d product rows; narrow it or filter by sku, product or currency", maxProductRows)})
 		return
 	}
 
-	slog.Info("dashboard fetched", "component", "api", "records", len(sales))
+	slog.Info("product dashboard fetched", "component", "api", "records", len(sales))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sales)
 }
 
+// GetTopProducts — GET /api/dashboard/top-products[?n=10&period=30d&currency=]
+//
+// Returns the n best-selling products by revenue over the last period of
+// UTC days, ranked separately within each currency.
+func (h *Handler) GetTopProducts(w http.ResponseWriter, r *http.Request) {
+	var v validator
+	query := v.topProductsQuery(r.URL.Query(), time.Now())
+	if !v.ok() {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", v.errs...)
+		return
+	}
+
+	top, err := h.DB.GetTopProducts(r.Context(), query)
+	if err != nil {
+		slog.Error("top products query failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to fetch dashboard data")
+		return
+	}
+
+	slog.Info("top products fetched", "component", "api", "records", len(top))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(top)
+}
+
 // ---------------------------------------------------------------------------
 // Admin
 // ---------------------------------------------------------------------------
 
-// RefreshMaterializedView — POST /api/admin/refresh
+// RefreshMaterializedViews — POST /api/admin/refresh
+//
+// Starts REFRESH MATERIALIZED VIEW CONCURRENTLY on every dashboard view as
+// a run of the mv_refresh job and returns 202 with the run at once: a
+// refresh can take minutes, far longer than the server's WriteTimeout.
+// Poll GET /api/admin/refresh/{id} for the outcome.
+//
+// Concurrent triggers share one refresh. If the job is already running,
+// on this replica or another, that run is returned instead of a new one.
+func (h *Handler) RefreshMaterializedViews(w http.ResponseWriter, r *http.Request) {
+	run, err := h.Jobs.Trigger(worker.MVRefreshJobName)
+	if errors.Is(err, database.ErrJobRunning) && run == nil {
+		// The running refresh finished between our insert and our read; try again.
+		run, err = h.Jobs.Trigger(worker.MVRefreshJobName)
+	}
+	switch {
+	case errors.Is(err, database.ErrJobRunning) && run != nil:
+		slog.Info("mv refresh already running", "component", "api", "run_id", run.ID)
+	case err != nil:
+		slog.Error("mv refresh trigger failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	default:
+		slog.Info("mv refresh triggered", "component", "api", "run_id", run.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/admin/refresh/%d", run.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GetRefresh — GET /api/admin/refresh/{id}
+//
+// Returns a refresh run started by POST /api/admin/refresh, or by the
+// schedule: its status is running, succeeded, failed or abandoned.
+func (h *Handler) GetRefresh(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeProblem(w, r, http.StatusNotFound, "refresh not found")
+		return
+	}
+
+	run, err := h.DB.GetJobRun(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && run.Job != worker.MVRefreshJobName) {
+		writeProblem(w, r, http.StatusNotFound, "refresh not found")
+		return
+	}
+	if err != nil {
+		slog.Error("refresh status read failed", "component", "api", "run_id", id, "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// ListJobs — GET /api/admin/jobs
 //
-// Manually triggers REFRESH MATERIALIZED VIEW CONCURRENTLY.
-// The DB layer applies its own refreshTimeout (5 min) so this does not race
-// against the HTTP server's WriteTimeout.
-func (h *Handler) RefreshMaterializedView(w http.ResponseWriter, r *http.Request) {
-	if err := h.DB.RefreshMaterializedView(r.Context()); err != nil {
-		slog.Error("manual mv refresh failed", "component", "api", "error", err)
-		http.Error(w, "failed to refresh view: "+err.Error(), http.StatusInternalServerError)
+// Lists the registered background jobs with their schedule, timeout and
+// most recent runs, newest first.
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.Jobs.Jobs(r.Context())
+	if err != nil {
+		slog.Error("job list failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
 		return
 	}
-	slog.Info("materialized view refreshed", "component", "api", "trigger", "manual")
-	w.Write([]byte("Materialized view refreshed successfully.\n"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"jobs": jobs})
+}
+
+// RunJob — POST /api/admin/jobs/{name}/run
+//
+// Starts a run of the named job on this replica, leader or not, and returns
+// 202 with the recorded run without waiting for it to finish. A job that is
+// already running anywhere in the cluster is not started twice: the
+// response is 409 naming the run in progress.
+func (h *Handler) RunJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	run, err := h.Jobs.Trigger(name)
+	switch {
+	case errors.Is(err, worker.ErrUnknownJob):
+		writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("no job named %q", name))
+		return
+	case errors.Is(err, database.ErrJobRunning):
+		detail := fmt.Sprintf("job %q is already running", name)
+		if run != nil {
+			detail = fmt.Sprintf("job %q is already running as run %d", name, run.ID)
+		}
+		writeProblem(w, r, http.StatusConflict, detail)
+		return
+	case err != nil:
+		slog.Error("job trigger failed", "component", "api", "job", name, "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	slog.Info("job triggered", "component", "api", "job", name, "run_id", run.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// ListExchangeRates — GET /api/admin/exchange-rates[?base=EUR&quote=USD]
+//
+// Returns stored rates, newest effective date first.
+func (h *Handler) ListExchangeRates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	rates, err := h.DB.ListExchangeRates(r.Context(), q.Get("base"), q.Get("quote"))
+	if err != nil {
+		slog.Error("exchange rate list failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"rates": rates})
+}
+
+// UpsertExchangeRates — POST /api/admin/exchange-rates
+//
+// Body: application/json {"rates": [{"base", "quote", "effective_date", "rate"}, ...]}
+// or text/csv with a base,quote,effective_date,rate header row.
+//
+// All rows are validated first, then written in one transaction; a rate for
+// an existing pair and date replaces it. The dashboard picks up new rates on
+// its next read — conversion is not materialized.
+func (h *Handler) UpsertExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, ok := decodeRates(w, r)
+	if !ok {
+		return
+	}
+	var v validator
+	v.exchangeRates(rates)
+	if v.writeIfInvalid(w, r) {
+		return
+	}
+
+	n, err := h.DB.UpsertExchangeRates(r.Context(), rates)
+	if err != nil {
+		slog.Error("exchange rate upsert failed", "component", "api", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "failed to store exchange rates")
+		return
+	}
+
+	slog.Info("exchange rates stored", "component", "api", "rates", n)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"upserted": n})
+}
+
+// bulkOrderRequest is the POST /api/bulk-orders body.
+type bulkOrderRequest struct {
+	Mode   database.BulkMode    `json:"mode"`
+	Orders []createOrderRequest `json:"orders"`
+}
+
+// bulkItemResult reports the outcome of one item, in request order.
+type bulkItemResult struct {
+	Index   int    `json:"index"`
+	OrderID string `json:"order_id,omitempty"`
+	Status  string `json:"status"` // "created" or "failed"
+	Error   string `json:"error,omitempty"`
 }
 
 // CreateBulkOrder — POST /api/bulk-orders
 //
-// Inserts two items in a single transaction.
-// Send {"item_1": "Laptop", "item_2": "ERROR"} to trigger a rollback.
+// Body: {"mode": "atomic" | "best_effort", "orders": [{"items": [...]}, ...]}
+//
+// Unlike POST /api/orders this is a synchronous write: all items are inserted
+// into Postgres in one transaction before the response is sent.
+//   - atomic (default): every item commits or none does. A failure returns a
+//     problem whose errors name the failing item: 422 if Postgres refused the
+//     order for its data, 500 otherwise.
+//   - best_effort: each item commits on its own. Returns 201 if all committed,
+//     207 Multi-Status with per-item results otherwise.
+//
+// Each order is written to the outbox in the same transaction, so the
+// response goes out as soon as the commit succeeds and the outbox relay
+// publishes the orders for the worker to index in ES (its Postgres insert is
+// an idempotent no-op). Nothing is cached; GetOrder fills the cache on a miss.
 func (h *Handler) CreateBulkOrder(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Item1 string `json:"item_1"`
-		Item2 string `json:"item_2"`
+	// A full-size batch may take longer than the server's WriteTimeout; give
+	// the response time to go out after the insert's own timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(database.BulkWriteTimeout + 5*time.Second)) //nolint:errcheck
+
+	var req bulkOrderRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	switch req.Mode {
+	case "":
+		req.Mode = database.BulkAtomic
+	case database.BulkAtomic, database.BulkBestEffort:
+	default:
+		v.add("mode", `must be "atomic" or "best_effort"`)
+	}
+	switch {
+	case len(req.Orders) == 0:
+		v.add("orders", "must contain at least one order")
+	case len(req.Orders) > maxBulkItems:
+		v.add("orders", "must contain at most %d orders", maxBulkItems)
+	}
+	items := make([][]models.OrderItem, len(req.Orders))
+	for i := range req.Orders {
+		items[i] = req.Orders[i].validate(&v, fmt.Sprintf("orders[%d].", i))
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if v.writeIfInvalid(w, r) {
 		return
 	}
 
-	if err := h.DB.ProcessBulkOrder(r.Context(), req.Item1, req.Item2); err != nil {
+	now := time.Now().UTC()
+	orders := make([]*models.Order, len(req.Orders))
+	for i := range req.Orders {
+		orders[i] = newOrder(req.Orders[i].Currency, items[i], now)
+	}
+
+	itemErrs, err := h.DB.InsertBulkOrders(r.Context(), orders, req.Mode)
+	if err != nil {
 		slog.Error("bulk order failed", "component", "api", "error", err)
-		http.Error(w, "transaction failed: "+err.Error(), http.StatusInternalServerError)
+		writeProblem(w, r, http.StatusInternalServerError, "transaction failed; no orders were created")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Bulk order processed and committed.\n"))
+	results := make([]bulkItemResult, len(orders))
+	var failed []FieldError
+	rejected := true // every failure is an order Postgres refused for its data
+	for i, o := range orders {
+		if itemErrs[i] != nil {
+			msg := bulkItemMessage(i, itemErrs[i])
+			results[i] = bulkItemResult{Index: i, Status: "failed", Error: msg}
+			if !errors.Is(itemErrs[i], database.ErrBulkRolledBack) {
+				failed = append(failed, FieldError{Field: fmt.Sprintf("orders[%d]", i), Message: msg})
+				rejected = rejected && errors.As(itemErrs[i], new(*database.OrderRejectedError))
+			}
+			continue
+		}
+		results[i] = bulkItemResult{Index: i, OrderID: o.ID, Status: "created"}
+	}
+
+	if req.Mode == database.BulkAtomic && len(failed) > 0 {
+		status := http.StatusInternalServerError
+		if rejected {
+			status = http.StatusUnprocessableEntity
+		}
+		slog.Error("bulk order rolled back", "component", "api", "items", len(orders))
+		writeProblem(w, r, status, "transaction rolled back; no orders were created", failed...)
+		return
+	}
+
+	status := http.StatusCreated
+	if len(failed) > 0 {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"mode":    req.Mode,
+		"results": results,
+	})
+}
+
+// bulkItemMessage is what the client is told about bulk item i's error.
+// Driver errors can quote SQL and values, so they are logged and reported
+// generically; only an order Postgres refused for its data is described.
+func bulkItemMessage(i int, err error) string {
+	var rejected *database.OrderRejectedError
+	switch {
+	case errors.Is(err, database.ErrBulkRolledBack):
+		return "rolled back with the rest of the batch"
+	case errors.As(err, &rejected):
+		return "rejected by the database: order " + rejected.Reason
+	default:
+		slog.Error("bulk order item failed", "component", "api", "index", i, "error", err)
+		return "could not be stored"
+	}
 }
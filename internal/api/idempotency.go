@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"go-polyglot-persistence/internal/cache"
+)
+
+// idempotencyHeader is the request header clients use to make a POST safe to retry.
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLen keeps keys to a sane size; clients typically send a UUID.
+const maxIdempotencyKeyLen = 255
+
+// IdempotencyStore is the contract for remembering Idempotency-Key responses.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string) (*cache.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, rec *cache.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// idempotent wraps a POST handler so a request carrying an Idempotency-Key
+// header runs at most once per key:
+//
+//   - first request        → runs next; a 2xx response is stored for replay
+//   - same key, same body  → the stored response is replayed (Idempotent-Replayed: true)
+//   - same key, other body → 422 Unprocessable Entity
+//   - first still running  → 409 Conflict; the client should retry later
+//
+// Non-2xx responses release the key so a retry runs again. Requests without
+// the header, or with no store configured, pass straight through.
+func (h *Handler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || h.Idempotency == nil {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeProblem(w, r, http.StatusBadRequest, "invalid headers",
+				FieldError{Field: idempotencyHeader, Message: fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLen)})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		var maxByteErr *http.MaxBytesError
+		if errors.As(err, &maxByteErr) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, bodyTooLargeDetail)
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped per route so one key cannot replay another endpoint's response.
+		scoped := r.URL.Path + ":" + key
+		ctx := r.Context()
+
+		existing, err := h.Idempotency.ReserveIdempotencyKey(ctx, scoped, fingerprint(body))
+		if err != nil {
+			slog.Error("idempotency reserve failed", "component", "api", "error", err)
+			writeProblem(w, r, http.StatusInternalServerError, "")
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint(body):
+				writeProblem(w, r, http.StatusUnprocessableEntity, "Idempotency-Key already used with a different payload")
+			case existing.StatusCode == 0:
+				writeProblem(w, r, http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+			default:
+				w.Header().Set("Content-Type", existing.ContentType)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(existing.StatusCode)
+				w.Write(existing.Body)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		// The client may have gone away; the key must still be settled.
+		ctx = context.WithoutCancel(ctx)
+
+		if rec.status < 200 || rec.status > 299 {
+			if err := h.Idempotency.ReleaseIdempotencyKey(ctx, scoped); err != nil {
+				slog.Error("idempotency release failed", "component", "api", "error", err)
+			}
+			return
+		}
+
+		if err := h.Idempotency.CompleteIdempotencyKey(ctx, scoped, &cache.IdempotencyRecord{
+			Fingerprint: fingerprint(body),
+			StatusCode:  rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		}); err != nil {
+			// The request succeeded; a retry would now run again, but it is not our error to report.
+			slog.Error("idempotency complete failed", "component", "api", "error", err)
+		}
+	}
+}
+
+// fingerprint hashes a canonical form of the JSON body, so re-encoding the
+// same payload with different whitespace or key order still matches.
+// Bodies that are not valid JSON are hashed as-is.
+func fingerprint(body []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // keep numbers exactly as sent
+
+	var v any
+	if err := dec.Decode(&v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body for the idempotency store.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.status = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
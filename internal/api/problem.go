@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// problemContentType is the RFC 7807 media type for error responses.
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Every error the API returns
+// uses this shape, so clients parse one format regardless of the endpoint.
+//
+// Type is left at "about:blank", which per the RFC means Title is simply the
+// HTTP status text. Errors lists per-field validation failures, if any.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one invalid field in a request body or query string.
+// Field is the JSON name (or query parameter), with indexes for array
+// elements, e.g. "items[2].amount".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// writeProblem sends a problem+json response for the given status.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string, errs ...FieldError) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Errors:   errs,
+	})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-polyglot-persistence/internal/models"
+)
+
+// maxRateRows bounds one exchange-rate upload; a year of daily rates for
+// a couple of dozen pairs fits comfortably.
+const maxRateRows = 10_000
+
+// rateLiteral matches what exchange_rates.rate (NUMERIC(20,10)) can hold.
+var rateLiteral = regexp.MustCompile(`^[0-9]{1,10}(\.[0-9]{1,10})?$`)
+
+// rateCSVColumns is the required CSV header, in any order.
+var rateCSVColumns = []string{"base", "quote", "effective_date", "rate"}
+
+// decodeRates reads an exchange-rate upload: either application/json
+// {"rates": [...]} or text/csv with a rateCSVColumns header. Like decodeJSON
+// it writes a problem response and returns false on failure.
+func decodeRates(w http.ResponseWriter, r *http.Request) ([]models.ExchangeRate, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		var req struct {
+			Rates []models.ExchangeRate `json:"rates"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return nil, false
+		}
+		return req.Rates, true
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	rates, err := parseRatesCSV(r.Body)
+	var maxByteErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxByteErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, bodyTooLargeDetail)
+		return nil, false
+	case err != nil:
+		writeProblem(w, r, http.StatusBadRequest, "malformed CSV: "+err.Error())
+		return nil, false
+	}
+	return rates, true
+}
+
+// parseRatesCSV reads a header row naming rateCSVColumns, then one rate per row.
+func parseRatesCSV(body io.Reader) ([]models.ExchangeRate, error) {
+	cr := csv.NewReader(body)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range rateCSVColumns {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("header must name the columns %s", strings.Join(rateCSVColumns, ", "))
+		}
+	}
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, models.ExchangeRate{
+			Base:          strings.TrimSpace(record[col["base"]]),
+			Quote:         strings.TrimSpace(record[col["quote"]]),
+			EffectiveDate: strings.TrimSpace(record[col["effective_date"]]),
+			Rate:          json.Number(strings.TrimSpace(record[col["rate"]])),
+		})
+	}
+}
+
+// exchangeRates checks an upload. Fields are named rates[i].field, where i
+// counts data rows from 0 for both JSON and CSV uploads.
+func (v *validator) exchangeRates(rates []models.ExchangeRate) {
+	switch {
+	case len(rates) == 0:
+		v.add("rates", "must contain at least one rate")
+		return
+	case len(rates) > maxRateRows:
+		v.add("rates", "must contain at most %d rates", maxRateRows)
+		return
+	}
+
+	seen := make(map[models.ExchangeRate]int, len(rates))
+	for i, r := range rates {
+		field := fmt.Sprintf("rates[%d].", i)
+		v.currency(field+"base", r.Base, true)
+		v.currency(field+"quote", r.Quote, true)
+		if r.Base != "" && r.Base == r.Quote {
+			v.add(field+"quote", "must differ from base")
+		}
+		if _, err := time.Parse(time.DateOnly, r.EffectiveDate); err != nil {
+			v.add(field+"effective_date", "must be a date in YYYY-MM-DD form")
+		}
+		if !rateLiteral.MatchString(r.Rate.String()) || strings.Trim(r.Rate.String(), "0.") == "" {
+			v.add(field+"rate", "must be a positive decimal with at most 10 integer and 10 fractional digits")
+		}
+
+		key := models.ExchangeRate{Base: r.Base, Quote: r.Quote, EffectiveDate: r.EffectiveDate}
+		if first, dup := seen[key]; dup {
+			v.add(field+"effective_date", "duplicates rates[%d]", first)
+		} else {
+			seen[key] = i
+		}
+	}
+}
@@ -11,18 +11,31 @@ import (
 // is visible at a glance without scrolling through handler logic.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// Orders
-	mux.HandleFunc("POST /api/orders", h.CreateOrder)
+	mux.HandleFunc("POST /api/orders", h.idempotent(h.CreateOrder))
+	mux.HandleFunc("GET /api/orders", h.ListOrders)
 	mux.HandleFunc("GET /api/orders/", h.GetOrder)
+	mux.HandleFunc("PATCH /api/orders/{id}", h.UpdateOrderStatus)
+	mux.HandleFunc("POST /api/orders/{id}/cancel", h.CancelOrder)
 
 	// Search
 	mux.HandleFunc("GET /api/search", h.SearchOrders)
+	mux.HandleFunc("GET /api/search/suggest", h.SuggestProducts)
 
-	// Dashboard (materialized view)
+	// Dashboard (materialized views)
 	mux.HandleFunc("GET /api/dashboard/sales", h.GetSalesDashboard)
+	mux.HandleFunc("GET /api/dashboard/products", h.GetProductsDashboard)
+	mux.HandleFunc("GET /api/dashboard/top-products", h.GetTopProducts)
 
 	// Admin
-	mux.HandleFunc("POST /api/admin/refresh", h.RefreshMaterializedView)
-	mux.HandleFunc("POST /api/bulk-orders", h.CreateBulkOrder)
+	mux.HandleFunc("POST /api/admin/refresh", h.RefreshMaterializedViews)
+	mux.HandleFunc("GET /api/admin/refresh/{id}", h.GetRefresh)
+	mux.HandleFunc("GET /api/admin/jobs", h.ListJobs)
+	mux.HandleFunc("POST /api/admin/jobs/{name}/run", h.RunJob)
+	mux.HandleFunc("GET /api/admin/exchange-rates", h.ListExchangeRates)
+	mux.HandleFunc("POST /api/admin/exchange-rates", h.UpsertExchangeRates)
+
+	// Bulk orders (synchronous, transactional)
+	mux.HandleFunc("POST /api/bulk-orders", h.idempotent(h.CreateBulkOrder))
 
 	// Observability
 	mux.Handle("GET /metrics", promhttp.Handler())
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestOrderQueryAmountNeedsCurrency(t *testing.T) {
+	tests := []struct {
+		query  string
+		fields []string
+	}{
+		{query: "q=laptop", fields: nil},
+		{query: "currency=EUR", fields: nil},
+		{query: "min_amount=10&currency=EUR", fields: nil},
+		{query: "min_amount=10&max_amount=20&currency=JPY", fields: nil},
+		{query: "min_amount=10", fields: []string{"currency"}},
+		{query: "max_amount=20", fields: []string{"currency"}},
+		{query: "min_amount=10&currency=XXX", fields: []string{"currency"}},
+		{query: "min_amount=20&max_amount=10&currency=EUR", fields: []string{"max_amount"}},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v validator
+		got := v.orderQuery(q)
+		var fields []string
+		for _, e := range v.errs {
+			fields = append(fields, e.Field)
+		}
+		if !slices.Equal(fields, tt.fields) {
+			t.Errorf("%s: errors on %v, want %v", tt.query, fields, tt.fields)
+		}
+		if got.Currency != q.Get("currency") {
+			t.Errorf("%s: Currency = %q", tt.query, got.Currency)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go-polyglot-persistence/internal/models"
+)
+
+// Request limits. maxBodyBytes is generous for a single order and for a bulk
+// request of maxBulkItems orders; anything larger is rejected before decoding.
+// maxAmount bounds both a unit price and an order total.
+const (
+	maxBodyBytes       = 1 << 20 // 1 MiB
+	maxProductNameLen  = 200
+	maxSKULen          = 64
+	maxOrderItems      = 100
+	maxQuantity        = 10_000
+	maxAmount          = models.Money(1_000_000 * 100)
+	maxBulkItems       = 1000
+	bodyTooLargeDetail = "request body exceeds 1 MiB"
+)
+
+// decodeJSON reads exactly one JSON value from the request body into dst.
+// Unknown fields, trailing data, wrong types and oversized bodies are all
+// rejected with a problem+json response; it returns false if it wrote one.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON value")
+	}
+	if err == nil {
+		return true
+	}
+
+	var (
+		syntaxErr  *json.SyntaxError
+		typeErr    *json.UnmarshalTypeError
+		maxByteErr *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &maxByteErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, bodyTooLargeDetail)
+	case errors.As(err, &syntaxErr):
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		writeProblem(w, r, http.StatusBadRequest, "request body is empty or truncated")
+	case errors.As(err, &typeErr):
+		writeProblem(w, r, http.StatusBadRequest, "invalid request body",
+			FieldError{Field: typeErr.Field, Message: "must be " + jsonTypeName(typeErr.Type)})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this; the message format is stable.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		writeProblem(w, r, http.StatusBadRequest, "invalid request body",
+			FieldError{Field: field, Message: "unknown field"})
+	default:
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+	}
+	return false
+}
+
+// jsonTypeName describes a Go type in JSON terms for error messages,
+// so clients never see Go type names such as float64.
+func jsonTypeName(t reflect.Type) string {
+	if t == reflect.TypeFor[json.Number]() {
+		return "a number"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// validator collects field errors so a client sees every problem at once,
+// not just the first.
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ok reports whether no errors were collected.
+func (v *validator) ok() bool { return len(v.errs) == 0 }
+
+// writeIfInvalid sends a 422 with the collected errors; it returns true if it did.
+func (v *validator) writeIfInvalid(w http.ResponseWriter, r *http.Request) bool {
+	if v.ok() {
+		return false
+	}
+	writeProblem(w, r, http.StatusUnprocessableEntity, "request failed validation", v.errs...)
+	return true
+}
+
+// productName checks a required, bounded, printable product name.
+func (v *validator) productName(field, name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		v.add(field, "is required")
+	case !utf8.ValidString(name):
+		v.add(field, "must be valid UTF-8")
+	case strings.ContainsFunc(name, unicode.IsControl):
+		v.add(field, "must not contain control characters")
+	case utf8.RuneCountInString(name) > maxProductNameLen:
+		v.add(field, "must be at most %d characters", maxProductNameLen)
+	case strings.TrimSpace(name) != name:
+		v.add(field, "must not have leading or trailing whitespace")
+	}
+}
+
+// sku checks a required, bounded SKU without whitespace or control characters.
+func (v *validator) sku(field, sku string) {
+	switch {
+	case sku == "":
+		v.add(field, "is required")
+	case !utf8.ValidString(sku):
+		v.add(field, "must be valid UTF-8")
+	case strings.ContainsFunc(sku, unicode.IsControl):
+		v.add(field, "must not contain control characters")
+	case utf8.RuneCountInString(sku) > maxSKULen:
+		v.add(field, "must be at most %d characters", maxSKULen)
+	case strings.ContainsFunc(sku, unicode.IsSpace):
+		v.add(field, "must not contain whitespace")
+	}
+}
+
+// quantity checks a positive, bounded item quantity.
+func (v *validator) quantity(field string, n int) {
+	switch {
+	case n <= 0:
+		v.add(field, "must be greater than 0")
+	case n > maxQuantity:
+		v.add(field, "must be at most %d", maxQuantity)
+	}
+}
+
+// amount parses and checks a positive money amount with at most two
+// decimals. It returns the parsed amount, or zero if it recorded an error.
+func (v *validator) amount(field, literal string) models.Money {
+	m, err := models.ParseMoney(literal)
+	switch {
+	case literal == "":
+		v.add(field, "is required")
+	case errors.Is(err, models.ErrMoneyPrecision):
+		v.add(field, "must have at most 2 decimal places")
+	case err != nil:
+		v.add(field, "must be a decimal number such as 12.50")
+	case m <= 0:
+		v.add(field, "must be greater than 0")
+	case m > maxAmount:
+		v.add(field, "must be at most %s", maxAmount)
+	default:
+		return m
+	}
+	return 0
+}
+
+// currency checks an ISO 4217 code against the supported currencies.
+// An empty code is accepted unless required.
+func (v *validator) currency(field, code string, required bool) {
+	if code == "" {
+		if required {
+			v.add(field, "is required")
+		}
+		return
+	}
+	if _, ok := models.CurrencyDecimals(code); !ok {
+		v.add(field, "must be a supported ISO 4217 currency code")
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProductName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"Gaming Laptop", true},
+		{"Café crème 250 g", true},
+		{strings.Repeat("é", maxProductNameLen), true},
+		{"", false},
+		{"   ", false},
+		{" Laptop", false},
+		{"Laptop\n", false},
+		{"Lap\x00top", false},
+		{"Lap\ttop", false},
+		{"Lap\u0085top", false},
+		{"Lap\xfftop", false},
+		{strings.Repeat("a", maxProductNameLen+1), false},
+	}
+	for _, tt := range tests {
+		var v validator
+		v.productName("product_name", tt.name)
+		if v.ok() != tt.ok {
+			t.Errorf("productName(%q): errors %+v, want ok = %v", tt.name, v.errs, tt.ok)
+		}
+	}
+}
+
+func TestSKU(t *testing.T) {
+	tests := []struct {
+		sku string
+		ok  bool
+	}{
+		{"LAP-001", true},
+		{"", false},
+		{"LAP 001", false},
+		{"LAP\x00", false},
+		{"LAP\x7f", false},
+		{"LAP\xc3", false},
+		{strings.Repeat("A", maxSKULen+1), false},
+	}
+	for _, tt := range tests {
+		var v validator
+		v.sku("sku", tt.sku)
+		if v.ok() != tt.ok {
+			t.Errorf("sku(%q): errors %+v, want ok = %v", tt.sku, v.errs, tt.ok)
+		}
+	}
+}
@@ -22,6 +22,13 @@ import (
 const (
 	orderKeyPrefix = "order:"
 	orderTTL       = 24 * time.Hour
+
+	idempotencyKeyPrefix = "idempotency:"
+	// idempotencyTTL is how long a completed response can be replayed.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyPendingTTL bounds a reservation whose request never completed
+	// (e.g. the API crashed), so the client can retry after it expires.
+	idempotencyPendingTTL = time.Minute
 )
 
 // ErrNotFound is returned when a key does not exist in the cache.
@@ -77,3 +84,69 @@ func (c *Client) GetOrder(ctx context.Context, id string) (*models.Order, error)
 	}
 	return &order, nil
 }
+
+// DeleteOrder removes an Order from Redis. Deleting a missing key is not an error.
+func (c *Client) DeleteOrder(ctx context.Context, id string) error {
+	return c.rdb.Del(ctx, orderKeyPrefix+id).Err()
+}
+
+// IdempotencyRecord is what Redis stores per Idempotency-Key.
+// StatusCode is 0 while the first request with the key is still in flight.
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// ReserveIdempotencyKey atomically claims key for a request with the given
+// payload fingerprint. It returns nil when the claim succeeded, or the record
+// already stored under key — pending or completed — when it did not.
+func (c *Client) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string) (*IdempotencyRecord, error) {
+	data, err := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	// Two rounds cover the key expiring between SETNX and GET.
+	for range 2 {
+		ok, err := c.rdb.SetNX(ctx, idempotencyKeyPrefix+key, data, idempotencyPendingTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, nil
+		}
+
+		existing, err := c.rdb.Get(ctx, idempotencyKeyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var rec IdempotencyRecord
+		if err := json.Unmarshal(existing, &rec); err != nil {
+			return nil, err
+		}
+		return &rec, nil
+	}
+	return nil, errors.New("cache: idempotency key contended")
+}
+
+// CompleteIdempotencyKey stores the final response for a reserved key so
+// later requests with the same key replay it for idempotencyTTL.
+func (c *Client) CompleteIdempotencyKey(ctx context.Context, key string, rec *IdempotencyRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return c.rdb.Set(ctx, idempotencyKeyPrefix+key, data, idempotencyTTL).Err()
+}
+
+// ReleaseIdempotencyKey drops a reservation whose request failed,
+// so the client can retry with the same key.
+func (c *Client) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return c.rdb.Del(ctx, idempotencyKeyPrefix+key).Err()
+}
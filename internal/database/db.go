@@ -3,14 +3,18 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"go-polyglot-persistence/internal/metrics"
 	"go-polyglot-persistence/internal/models"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -22,15 +26,204 @@ const (
 	readTimeout    = 5 * time.Second
 	writeTimeout   = 5 * time.Second
 	refreshTimeout = 5 * time.Minute // REFRESH MATERIALIZED VIEW can be slow
+
+	// BulkWriteTimeout caps InsertBulkOrders. A bulk request of up to 1000
+	// orders writes each one's row, items, outbox row and rollup share in
+	// one transaction, far more than writeTimeout allows for. It is exported
+	// so the bulk handler can extend its write deadline past it.
+	BulkWriteTimeout = 30 * time.Second
 )
 
-type DailySale struct {
-	Date         string  `json:"date"`
-	TotalRevenue float64 `json:"total_revenue"`
+// ErrInvalidTransition is returned by UpdateOrderStatus when the order's
+// current status cannot move to the requested one.
+var ErrInvalidTransition = errors.New("database: invalid status transition")
+
+// orderColumns is the column list every order read selects, in scanOrder order.
+// Items live in order_items and are attached afterwards by loadItems.
+const orderColumns = "id, amount, currency, status, created_at, updated_at"
+
+// scanOrder scans one row selected with orderColumns.
+func scanOrder(row interface{ Scan(...any) error }, o *models.Order) error {
+	return row.Scan(&o.ID, &o.Amount, &o.Currency, &o.Status, &o.CreatedAt, &o.UpdatedAt)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// loadItems attaches every order's line items, in line order, with one query.
+func loadItems(ctx context.Context, q querier, orders ...*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*models.Order, len(orders))
+	ids := make([]string, len(orders))
+	for i, o := range orders {
+		o.Items = []models.OrderItem{}
+		byID[o.ID] = o
+		ids[i] = o.ID
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT order_id, sku, product_name, quantity, unit_price
+		 FROM order_items
+		 WHERE order_id = ANY($1)
+		 ORDER BY order_id, line`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID string
+			item    models.OrderItem
+		)
+		if err := rows.Scan(&orderID, &item.SKU, &item.ProductName, &item.Quantity, &item.UnitPrice); err != nil {
+			return err
+		}
+		o := byID[orderID]
+		o.Items = append(o.Items, item)
+	}
+	return rows.Err()
+}
+
+// Postgres caps a statement at 65535 bind parameters. Multi-row inserts are
+// chunked to stay well below it.
+const maxRowsPerInsert = 1000
+
+// insertOrders writes orders and their items inside tx. With ignoreConflicts
+// an order that already exists is skipped together with its items, which
+// makes replaying a creation a no-op. With IncrementalSales the orders it
+// actually inserted are added to the sales rollup in the same transaction.
+func (db *DB) insertOrders(ctx context.Context, tx *sql.Tx, orders []*models.Order, ignoreConflicts bool) error {
+	inserted := make([]string, 0, len(orders))
+	for chunk := range slices.Chunk(orders, maxRowsPerInsert) {
+		var query strings.Builder
+		query.WriteString("INSERT INTO orders (id, amount, currency, status, created_at, updated_at) VALUES ")
+		args := make([]any, 0, len(chunk)*6)
+		for i, o := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, o.ID, o.Amount, o.Currency, o.Status, o.CreatedAt, o.UpdatedAt)
+		}
+		if ignoreConflicts {
+			query.WriteString(" ON CONFLICT (id) DO NOTHING")
+		}
+		query.WriteString(" RETURNING id")
+		ids, err := queryIDs(ctx, tx, query.String(), args...)
+		if err != nil {
+			return err
+		}
+		inserted = append(inserted, ids...)
+	}
+
+	type line struct {
+		orderID string
+		n       int
+		item    models.OrderItem
+	}
+	var lines []line
+	for _, o := range orders {
+		for i, item := range o.Items {
+			lines = append(lines, line{o.ID, i + 1, item})
+		}
+	}
+
+	for chunk := range slices.Chunk(lines, maxRowsPerInsert) {
+		var query strings.Builder
+		query.WriteString("INSERT INTO order_items (order_id, line, sku, product_name, quantity, unit_price) VALUES ")
+		args := make([]any, 0, len(chunk)*6)
+		for i, l := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, l.orderID, l.n, l.item.SKU, l.item.ProductName, l.item.Quantity, l.item.UnitPrice)
+		}
+		if ignoreConflicts {
+			query.WriteString(" ON CONFLICT (order_id, line) DO NOTHING")
+		}
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+
+	if db.IncrementalSales {
+		return rollupSales(ctx, tx, inserted)
+	}
+	return nil
+}
+
+// queryIDs runs a statement returning one id column and collects the ids.
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// OrderFilter narrows and pages ListOrders. Zero values mean "no filter".
+// ProductName and SKU match orders with at least one such item; the amount
+// bounds apply to the order total, which is only comparable within one
+// Currency. CreatedFrom is inclusive and CreatedTo exclusive, so adjacent
+// ranges never overlap. UpdatedFrom (inclusive) selects orders changed
+// since a point in time. After is the keyset position of the last row of
+// the previous page.
+type OrderFilter struct {
+	ProductName string
+	SKU         string
+	Status      models.OrderStatus
+	Currency    string
+	MinAmount   *models.Money
+	MaxAmount   *models.Money
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	Descending  bool
+	Limit       int
+	After       *OrderCursor
+}
+
+// OrderCursor is a keyset position in the (created_at, id) ordering.
+// id breaks ties between orders created in the same instant.
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        string
 }
 
 type DB struct {
 	Conn *sql.DB
+
+	// IncrementalSales selects SALES_AGGREGATION=incremental: inserts add
+	// each new order to the sales_quarter_hour rollup in their transaction,
+	// the dashboard reads that table, and RefreshMaterializedViews leaves
+	// sales_quarter_hour_mv alone. Every process writing orders must agree.
+	IncrementalSales bool
 }
 
 // Connect opens and verifies a Postgres connection.
@@ -54,125 +247,600 @@ func (db *DB) GetOrderByID(ctx context.Context, id string) (*models.Order, error
 	defer cancel()
 
 	var o models.Order
-	err := db.Conn.QueryRowContext(ctx,
-		"SELECT id, product_name, amount, created_at FROM orders WHERE id = $1",
+	err := scanOrder(db.Conn.QueryRowContext(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE id = $1",
 		id,
-	).Scan(&o.ID, &o.ProductName, &o.Amount, &o.CreatedAt)
+	), &o)
 	if err != nil {
 		return nil, err
 	}
+	if err := loadItems(ctx, db.Conn, &o); err != nil {
+		return nil, err
+	}
 	return &o, nil
 }
 
-// GetSales queries the daily_sales_mv materialized view.
-func (db *DB) GetSales(ctx context.Context) ([]DailySale, error) {
+// ListOrders returns one page of orders in (created_at, id) order.
+// Keyset pagination keeps every page an index range scan on
+// orders_created_at_id_idx, no matter how deep the client pages — unlike
+// OFFSET, which re-reads and discards every earlier row.
+func (db *DB) ListOrders(ctx context.Context, f OrderFilter) ([]models.Order, error) {
 	ctx, cancel := context.WithTimeout(ctx, readTimeout)
 	defer cancel()
 
-	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("read_dashboard"))
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("list_orders"))
 	defer timer.ObserveDuration()
 
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.ProductName != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM order_items i WHERE i.order_id = orders.id AND i.product_name = "+arg(f.ProductName)+")")
+	}
+	if f.SKU != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM order_items i WHERE i.order_id = orders.id AND i.sku = "+arg(f.SKU)+")")
+	}
+	if f.Status != "" {
+		where = append(where, "status = "+arg(f.Status))
+	}
+	if f.Currency != "" {
+		where = append(where, "currency = "+arg(f.Currency))
+	}
+	if f.MinAmount != nil {
+		where = append(where, "amount >= "+arg(*f.MinAmount))
+	}
+	if f.MaxAmount != nil {
+		where = append(where, "amount <= "+arg(*f.MaxAmount))
+	}
+	if !f.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= "+arg(f.CreatedFrom))
+	}
+	if !f.CreatedTo.IsZero() {
+		where = append(where, "created_at < "+arg(f.CreatedTo))
+	}
+	if !f.UpdatedFrom.IsZero() {
+		where = append(where, "updated_at >= "+arg(f.UpdatedFrom))
+	}
+
+	order, cmp := "ASC", ">"
+	if f.Descending {
+		order, cmp = "DESC", "<"
+	}
+	if f.After != nil {
+		// Row comparison matches the composite index, so Postgres seeks straight to the cursor.
+		where = append(where, fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, arg(f.After.CreatedAt), arg(f.After.ID)))
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s LIMIT %s", order, order, arg(f.Limit))
+
+	rows, err := db.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err := scanOrder(rows, &o); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	page := make([]*models.Order, len(orders))
+	for i := range orders {
+		page[i] = &orders[i]
+	}
+	if err := loadItems(ctx, db.Conn, page...); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// OrderVersion is an order's ID and last change, enough to tell whether a
+// copy of it elsewhere is current.
+type OrderVersion struct {
+	ID        string
+	UpdatedAt time.Time
+}
+
+// ListOrderVersions returns up to limit orders with IDs after afterID ("" for
+// the first page), in ID order, skipping orders changed at or after before.
+// The primary key index serves every page, so a full walk reads each row
+// once.
+func (db *DB) ListOrderVersions(ctx context.Context, afterID string, before time.Time, limit int) ([]OrderVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
 	rows, err := db.Conn.QueryContext(ctx,
-		"SELECT sale_date, total_revenue FROM daily_sales_mv ORDER BY sale_date DESC LIMIT 30",
+		"SELECT id, updated_at FROM orders WHERE id > $1 AND updated_at < $2 ORDER BY id LIMIT $3",
+		afterID, before, limit,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var sales []DailySale
+	var versions []OrderVersion
 	for rows.Next() {
-		var s DailySale
-		if err := rows.Scan(&s.Date, &s.TotalRevenue); err != nil {
-			slog.Error("scan failed", "op", "get_sales", "error", err)
-			continue
+		var v OrderVersion
+		if err := rows.Scan(&v.ID, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetOrdersByID fetches the orders with the given IDs, with their items.
+// IDs that do not exist are left out.
+func (db *DB) GetOrdersByID(ctx context.Context, ids []string) ([]*models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	rows, err := db.Conn.QueryContext(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE id = ANY($1) ORDER BY id",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		var o models.Order
+		if err := scanOrder(rows, &o); err != nil {
+			return nil, err
 		}
-		sales = append(sales, s)
+		orders = append(orders, &o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return sales, rows.Err()
+	rows.Close()
+
+	if err := loadItems(ctx, db.Conn, orders...); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ExistingOrderIDs reports which of ids exist in orders.
+func (db *DB) ExistingOrderIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	rows, err := db.Conn.QueryContext(ctx, "SELECT id FROM orders WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exists := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		exists[id] = true
+	}
+	return exists, rows.Err()
 }
 
-// RefreshMaterializedView triggers REFRESH MATERIALIZED VIEW CONCURRENTLY.
-// CONCURRENTLY means reads are not blocked, but a unique index is required.
-// This operation can be slow on large tables — it gets its own long timeout,
-// separate from the HTTP request context, so an admin trigger does not race
-// against the server's WriteTimeout.
-func (db *DB) RefreshMaterializedView(ctx context.Context) error {
+// materializedViews lists the dashboard views RefreshMaterializedViews
+// keeps current, in refresh order.
+var materializedViews = []string{"sales_quarter_hour_mv", "product_sales_daily_mv"}
+
+// RefreshMaterializedViews runs REFRESH MATERIALIZED VIEW CONCURRENTLY on
+// every dashboard view in turn. CONCURRENTLY means reads are not blocked,
+// but each view needs a unique index. This operation can be slow on large
+// tables — it gets its own long timeout, separate from the HTTP request
+// context, so an admin trigger does not race against the server's
+// WriteTimeout. It stops at the first view that fails. With
+// IncrementalSales the sales view is skipped: nothing reads it.
+func (db *DB) RefreshMaterializedViews(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
 	defer cancel()
 
 	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("refresh_mv"))
 	defer timer.ObserveDuration()
 
-	_, err := db.Conn.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY daily_sales_mv")
-	return err
+	for _, view := range materializedViews {
+		if view == "sales_quarter_hour_mv" && db.IncrementalSales {
+			continue // superseded by the sales_quarter_hour rollup
+		}
+		if _, err := db.Conn.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY "+view); err != nil {
+			return fmt.Errorf("refresh %s: %w", view, err)
+		}
+	}
+	return nil
 }
 
-// InsertOrder inserts a single order row.
-func (db *DB) InsertOrder(ctx context.Context, productName string, amount float64) error {
+// InsertOrderIdempotent inserts an order and its items by its pre-assigned
+// UUID, in one transaction so an order is never visible without its items.
+// ON CONFLICT DO NOTHING makes retries safe — replaying the same message
+// from RabbitMQ will not create duplicate rows.
+func (db *DB) InsertOrderIdempotent(ctx context.Context, o *models.Order) error {
+	return db.InsertOrdersIdempotent(ctx, []*models.Order{o})
+}
+
+// InsertOrdersIdempotent inserts a batch of orders and their items with
+// multi-row statements in one transaction. Orders that already exist are
+// skipped along with their items — duplicates within the batch too.
+// The transaction is all-or-nothing: if any row violates a constraint the
+// whole batch fails and the caller should fall back to per-row inserts.
+func (db *DB) InsertOrdersIdempotent(ctx context.Context, orders []*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
 	defer cancel()
 
-	_, err := db.Conn.ExecContext(ctx,
-		"INSERT INTO orders (product_name, amount, created_at) VALUES ($1, $2, NOW())",
-		productName, amount,
-	)
-	return err
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("insert_batch"))
+	defer timer.ObserveDuration()
+
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := db.insertOrders(ctx, tx, orders, true); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// InsertOrderIdempotent inserts an order by its pre-assigned UUID.
-// ON CONFLICT DO NOTHING makes retries safe — replaying the same message
-// from RabbitMQ will not create duplicate rows.
-func (db *DB) InsertOrderIdempotent(ctx context.Context, o *models.Order) error {
+// UpdateOrderStatus moves an order to status `to` and returns the updated row.
+// The row is locked while the transition is checked, so two concurrent
+// changes cannot both pass validation.
+//
+// Re-applying a change the order already has is a no-op that returns the
+// current row — replaying the same queue message is safe. With
+// IncrementalSales, an order that is cancelled or refunded is taken out of
+// the sales rollup in the same transaction.
+// Returns sql.ErrNoRows if the order does not exist (yet) and
+// ErrInvalidTransition if the current status cannot move to `to`.
+func (db *DB) UpdateOrderStatus(ctx context.Context, id string, to models.OrderStatus, at time.Time) (*models.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var o models.Order
+	if err := scanOrder(tx.QueryRowContext(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE id = $1 FOR UPDATE",
+		id,
+	), &o); err != nil {
+		return nil, err
+	}
+
+	if o.Status == to {
+		if err := loadItems(ctx, tx, &o); err != nil {
+			return nil, err
+		}
+		return &o, nil
+	}
+	if !o.Status.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, o.Status, to)
+	}
+	if db.IncrementalSales && o.Status.IsSale() && !to.IsSale() {
+		if err := unrollupSales(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanOrder(tx.QueryRowContext(ctx,
+		"UPDATE orders SET status = $2, updated_at = $3 WHERE id = $1 RETURNING "+orderColumns,
+		id, to, at,
+	), &o); err != nil {
+		return nil, err
+	}
+	if err := loadItems(ctx, tx, &o); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// EnqueueOrder commits an order to the outbox table. Once this returns nil the
+// order is guaranteed to reach the queue eventually via RelayOutbox, so the
+// API never acknowledges an order that could be lost between two writes.
+func (db *DB) EnqueueOrder(ctx context.Context, o *models.Order) error {
 	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
 	defer cancel()
 
-	_, err := db.Conn.ExecContext(ctx,
-		`INSERT INTO orders (id, product_name, amount, created_at)
-		 VALUES ($1, $2, $3, $4)
-		 ON CONFLICT (id) DO NOTHING`,
-		o.ID, o.ProductName, o.Amount, o.CreatedAt,
+	return enqueueOrder(ctx, db.Conn, o)
+}
+
+// enqueueOrder writes o's outbox row through e, which may be the
+// transaction that inserts the order itself.
+func enqueueOrder(ctx context.Context, e execer, o *models.Order) error {
+	payload, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.ExecContext(ctx,
+		"INSERT INTO outbox (aggregate_id, payload) VALUES ($1, $2)",
+		o.ID, payload,
 	)
 	return err
 }
 
-// ProcessBulkOrder inserts two items inside a single transaction.
-// If item2 == "ERROR" the transaction is rolled back to demonstrate
-// atomicity. The deferred Rollback is a no-op after a successful Commit.
-func (db *DB) ProcessBulkOrder(ctx context.Context, item1, item2 string) error {
+// ErrBrokerUnavailable marks a publish error that is not about the message
+// being published, such as the broker being unreachable. RelayOutbox ends
+// the pass on it without counting an attempt against the row, since every
+// row behind it would fail the same way.
+var ErrBrokerUnavailable = errors.New("database: broker unavailable")
+
+// RelayOutbox publishes up to limit unsent outbox rows, oldest first, and
+// marks the published ones as sent. It returns how many rows were sent.
+//
+// Rows are locked with FOR UPDATE SKIP LOCKED, so several API replicas can
+// relay concurrently without publishing the same row twice in the common case.
+// A crash between publish and commit re-sends the row on the next pass —
+// delivery is at-least-once and the worker is idempotent on order ID.
+//
+// A row that fails to publish gets its attempts counted and its last error
+// recorded, and the pass moves on to the next row: every row is a different
+// order, so skipping one reorders nothing that matters. Once a row has
+// failed maxAttempts times it is parked and no longer relayed; a payload
+// that cannot be decoded is parked at once. An error wrapping
+// ErrBrokerUnavailable ends the pass instead. Rows published before a
+// failure are still marked sent, and the failures are returned joined.
+func (db *DB) RelayOutbox(ctx context.Context, limit, maxAttempts int, publish func(context.Context, *models.Order) error) (int, error) {
 	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
 	defer cancel()
 
 	tx, err := db.Conn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	if _, err = tx.ExecContext(ctx,
-		"INSERT INTO orders (product_name, amount, created_at) VALUES ($1, $2, NOW())",
-		item1, 100.00,
-	); err != nil {
-		return err
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, payload, attempts FROM outbox
+		 WHERE sent_at IS NULL AND parked_at IS NULL
+		 ORDER BY id
+		 LIMIT $1
+		 FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return 0, err
 	}
-	slog.Info("bulk order step 1 ok", "item", item1)
 
-	if item2 == "ERROR" {
-		slog.Warn("bulk order simulating failure", "item", item2)
-		return errors.New("simulated failure at step 2")
+	type pending struct {
+		id       int64
+		payload  []byte
+		attempts int
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.payload, &p.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
 	}
 
-	if _, err = tx.ExecContext(ctx,
-		"INSERT INTO orders (product_name, amount, created_at) VALUES ($1, $2, NOW())",
-		item2, 50.00,
-	); err != nil {
+	var sent []int64
+	var errs []error
+	for _, p := range batch {
+		var o models.Order
+		if err := json.Unmarshal(p.payload, &o); err != nil {
+			err = fmt.Errorf("outbox row %d: %w", p.id, err)
+			if err := failOutboxRow(ctx, tx, p.id, err, true); err != nil {
+				return 0, err
+			}
+			errs = append(errs, fmt.Errorf("%w (parked)", err))
+			continue
+		}
+		if err := publish(ctx, &o); err != nil {
+			err = fmt.Errorf("outbox row %d: %w", p.id, err)
+			if errors.Is(err, ErrBrokerUnavailable) {
+				errs = append(errs, err)
+				break
+			}
+			park := p.attempts+1 >= maxAttempts
+			if err := failOutboxRow(ctx, tx, p.id, err, park); err != nil {
+				return 0, err
+			}
+			if park {
+				err = fmt.Errorf("%w (parked after %d attempts)", err, p.attempts+1)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		sent = append(sent, p.id)
+	}
+
+	if len(sent) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE outbox SET sent_at = now() WHERE id = ANY($1)",
+			pq.Array(sent),
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(sent), errors.Join(errs...)
+}
+
+// failOutboxRow counts a failed publish of outbox row id and records why,
+// parking the row if park is set.
+func failOutboxRow(ctx context.Context, tx *sql.Tx, id int64, cause error, park bool) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE outbox
+		 SET attempts = attempts + 1,
+		     last_error = $2,
+		     parked_at = CASE WHEN $3 THEN now() END
+		 WHERE id = $1`,
+		id, cause.Error(), park,
+	)
+	return err
+}
+
+// DeleteSentOutboxBefore deletes outbox rows published before cutoff and
+// returns how many it removed. Unsent rows, parked ones included, are
+// always kept.
+func (db *DB) DeleteSentOutboxBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := db.Conn.ExecContext(ctx,
+		"DELETE FROM outbox WHERE sent_at < $1",
+		cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// BulkMode selects how InsertBulkOrders treats a failing item.
+type BulkMode string
+
+const (
+	// BulkAtomic commits every item or none: the first failure rolls back the batch.
+	BulkAtomic BulkMode = "atomic"
+	// BulkBestEffort commits every item that succeeds; each item runs under its
+	// own SAVEPOINT so one failure does not abort the surrounding transaction.
+	BulkBestEffort BulkMode = "best_effort"
+)
+
+// ErrBulkRolledBack is the per-item error for items that were valid but were
+// rolled back because another item failed in BulkAtomic mode.
+var ErrBulkRolledBack = errors.New("database: rolled back with the rest of the batch")
+
+// OrderRejectedError is the per-item error of InsertBulkOrders for an order
+// Postgres refused because of its own data: a constraint violation or a
+// value out of range. Reason names the cause without quoting the driver
+// error, which can include SQL and values; Err keeps it for logs.
+type OrderRejectedError struct {
+	Reason string
+	Err    error
+}
+
+func (e *OrderRejectedError) Error() string {
+	return "database: order rejected: " + e.Reason + ": " + e.Err.Error()
+}
+
+func (e *OrderRejectedError) Unwrap() error { return e.Err }
+
+// classifyInsertError wraps err in an OrderRejectedError if Postgres
+// refused the row for its data, and returns any other error unchanged.
+func classifyInsertError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
 		return err
 	}
-	slog.Info("bulk order step 2 ok", "item", item2)
+	switch pqErr.Code.Class() {
+	case "23": // integrity_constraint_violation
+		reason := "violates a constraint"
+		if pqErr.Constraint != "" {
+			reason = "violates constraint " + pqErr.Constraint
+		}
+		return &OrderRejectedError{Reason: reason, Err: err}
+	case "22": // data_exception
+		return &OrderRejectedError{Reason: "has a value the database cannot store", Err: err}
+	}
+	return err
+}
+
+// InsertBulkOrders inserts orders in a single transaction, together with an
+// outbox row for each, so the relay publishes every committed order for the
+// worker to index, and no order is published that did not commit.
+//
+// The returned slice is aligned with orders: a nil entry means the row is
+// committed. An item Postgres refused for its data has an
+// *OrderRejectedError. In BulkAtomic mode a failure leaves the failing
+// item's error in its slot and ErrBulkRolledBack in every other slot. The
+// error return is for failures of the transaction itself (begin/commit),
+// after which no row is committed regardless of the slice.
+func (db *DB) InsertBulkOrders(ctx context.Context, orders []*models.Order, mode BulkMode) ([]error, error) {
+	ctx, cancel := context.WithTimeout(ctx, BulkWriteTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("insert_bulk"))
+	defer timer.ObserveDuration()
+
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	itemErrs := make([]error, len(orders))
+	for i, o := range orders {
+		if mode == BulkAtomic {
+			if err := db.insertBulkItem(ctx, tx, o); err != nil {
+				for j := range itemErrs {
+					itemErrs[j] = ErrBulkRolledBack
+				}
+				itemErrs[i] = classifyInsertError(err)
+				slog.Warn("bulk order rolled back", "index", i, "error", err)
+				return itemErrs, nil
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT bulk_item"); err != nil {
+			return nil, err
+		}
+		if err := db.insertBulkItem(ctx, tx, o); err != nil {
+			itemErrs[i] = classifyInsertError(err)
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT bulk_item"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT bulk_item"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	slog.Info("bulk order committed", "mode", mode, "items", len(orders))
+	return itemErrs, nil
+}
 
-	if err = tx.Commit(); err != nil {
+// insertBulkItem inserts one bulk order and its outbox row in tx.
+func (db *DB) insertBulkItem(ctx context.Context, tx *sql.Tx, o *models.Order) error {
+	if err := db.insertOrders(ctx, tx, []*models.Order{o}, false); err != nil {
 		return err
 	}
-	slog.Info("bulk order committed")
-	return nil
+	return enqueueOrder(ctx, tx, o)
 }
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-polyglot-persistence/internal/metrics"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrJobRunning is returned by StartJobRun when the job already has a run
+// in progress on some replica.
+var ErrJobRunning = errors.New("database: job is already running")
+
+// Job run statuses and triggers, as stored in job_runs.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobAbandoned = "abandoned" // the replica died; found after the deadline
+
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+	TriggerElection = "election" // run when a replica became cron leader
+)
+
+// JobRun is one recorded run of a scheduled job.
+type JobRun struct {
+	ID         int64      `json:"id"`
+	Job        string     `json:"job"`
+	Trigger    string     `json:"trigger"`
+	Replica    string     `json:"replica"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	Deadline   time.Time  `json:"deadline"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+const jobRunColumns = "id, job, trigger, replica, status, started_at, deadline, finished_at, COALESCE(error, '')"
+
+func scanJobRun(row interface{ Scan(...any) error }, r *JobRun) error {
+	return row.Scan(&r.ID, &r.Job, &r.Trigger, &r.Replica, &r.Status,
+		&r.StartedAt, &r.Deadline, &r.FinishedAt, &r.Error)
+}
+
+// StartJobRun records the start of a run of job that must finish within
+// timeout. A unique index allows one running run per job, so this is also
+// the cluster-wide guard against overlapping runs: if the job is already
+// running it returns that run and ErrJobRunning. Runs left 'running' past
+// their deadline by a replica that died are marked abandoned first, so a
+// crash never blocks a job for longer than its timeout.
+func (db *DB) StartJobRun(ctx context.Context, job, trigger, replica string, timeout time.Duration) (*JobRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("start_job_run"))
+	defer timer.ObserveDuration()
+
+	if _, err := db.Conn.ExecContext(ctx, `
+		UPDATE job_runs
+		SET status = 'abandoned', finished_at = now(), error = 'no result before the deadline'
+		WHERE job = $1 AND status = 'running' AND deadline < now()`,
+		job,
+	); err != nil {
+		return nil, err
+	}
+
+	var run JobRun
+	err := scanJobRun(db.Conn.QueryRowContext(ctx, `
+		INSERT INTO job_runs (job, trigger, replica, status, deadline)
+		VALUES ($1, $2, $3, 'running', now() + $4::float8 * interval '1 millisecond')
+		RETURNING `+jobRunColumns,
+		job, trigger, replica, timeout.Milliseconds(),
+	), &run)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		err = scanJobRun(db.Conn.QueryRowContext(ctx,
+			"SELECT "+jobRunColumns+" FROM job_runs WHERE job = $1 AND status = 'running'",
+			job,
+		), &run)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The running run finished in between; the caller may retry.
+			return nil, ErrJobRunning
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &run, ErrJobRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FinishJobRun records the outcome of run id: succeeded if runErr is nil,
+// failed with its message otherwise.
+func (db *DB) FinishJobRun(ctx context.Context, id int64, runErr error) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	status, message := JobSucceeded, sql.NullString{}
+	if runErr != nil {
+		status, message = JobFailed, sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	_, err := db.Conn.ExecContext(ctx,
+		"UPDATE job_runs SET status = $2, finished_at = now(), error = $3 WHERE id = $1 AND status = 'running'",
+		id, status, message,
+	)
+	return err
+}
+
+// GetJobRun fetches one run. Returns sql.ErrNoRows when the ID does not exist.
+func (db *DB) GetJobRun(ctx context.Context, id int64) (*JobRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	var run JobRun
+	err := scanJobRun(db.Conn.QueryRowContext(ctx,
+		"SELECT "+jobRunColumns+" FROM job_runs WHERE id = $1",
+		id,
+	), &run)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RecentJobRuns returns up to perJob most recent runs of each job, keyed by
+// job name, newest first.
+func (db *DB) RecentJobRuns(ctx context.Context, perJob int) (map[string][]JobRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("list_job_runs"))
+	defer timer.ObserveDuration()
+
+	rows, err := db.Conn.QueryContext(ctx, `
+		SELECT `+jobRunColumns+` FROM (
+			SELECT *, row_number() OVER (PARTITION BY job ORDER BY started_at DESC, id DESC) AS n
+			FROM job_runs
+		) recent
+		WHERE n <= $1
+		ORDER BY job, started_at DESC, id DESC`,
+		perJob,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[string][]JobRun)
+	for rows.Next() {
+		var r JobRun
+		if err := scanJobRun(rows, &r); err != nil {
+			return nil, err
+		}
+		runs[r.Job] = append(runs[r.Job], r)
+	}
+	return runs, rows.Err()
+}
+
+// DeleteJobRunsBefore deletes finished runs that started before cutoff and
+// returns how many it removed. Running runs are always kept.
+func (db *DB) DeleteJobRunsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("delete_job_runs"))
+	defer timer.ObserveDuration()
+
+	res, err := db.Conn.ExecContext(ctx,
+		"DELETE FROM job_runs WHERE started_at < $1 AND status <> 'running'",
+		cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
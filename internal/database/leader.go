@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// ErrLeadershipLost is returned by Leadership.Check when the session is
+// alive but no longer holds the lock.
+var ErrLeadershipLost = errors.New("database: advisory lock no longer held")
+
+// Leadership is a session-level Postgres advisory lock held on a dedicated
+// connection. Postgres releases the lock when the session ends, so a
+// crashed or partitioned holder cannot keep it.
+type Leadership struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryLeadership makes one attempt to take the advisory lock key. It returns
+// nil and no error if another session holds it.
+func (db *DB) TryLeadership(ctx context.Context, key int64) (*Leadership, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	conn, err := db.Conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		discard(conn)
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &Leadership{conn: conn, key: key}, nil
+}
+
+// Check confirms the session is alive and still holds the lock. A bigint
+// advisory key is split across pg_locks.classid (high half) and objid.
+func (l *Leadership) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	var held bool
+	err := l.conn.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND granted AND pid = pg_backend_pid()
+			  AND objsubid = 1 AND ((classid::bigint << 32) | objid::bigint) = $1
+		)`,
+		l.key,
+	).Scan(&held)
+	if err == nil && !held {
+		err = ErrLeadershipLost
+	}
+	return err
+}
+
+// Release gives up leadership by ending the session rather than unlocking:
+// a connection returned to the pool while still holding the lock would keep
+// it forever, and closing the session cannot fail to release it.
+func (l *Leadership) Release() {
+	discard(l.conn)
+}
+
+// discard closes conn's session instead of returning it to the pool.
+func discard(conn *sql.Conn) {
+	conn.Raw(func(any) error { return driver.ErrBadConn }) //nolint:errcheck
+	conn.Close()
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-polyglot-persistence/internal/metrics"
+	"go-polyglot-persistence/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProductTotals is one product's sales in one currency. A product is a
+// (SKU, ProductName) pair; lines from before SKUs existed have an empty SKU.
+// AverageOrderValue is Revenue divided by OrderCount: what an order that
+// contains the product spends on it.
+type ProductTotals struct {
+	SKU               string       `json:"sku"`
+	ProductName       string       `json:"product_name"`
+	Currency          string       `json:"currency"`
+	Revenue           models.Money `json:"revenue"`
+	UnitsSold         int64        `json:"units_sold"`
+	OrderCount        int64        `json:"order_count"`
+	AverageOrderValue models.Money `json:"average_order_value"`
+}
+
+// ProductSales is one product's sales on one UTC day.
+type ProductSales struct {
+	Date string `json:"date"`
+	ProductTotals
+}
+
+// TopProduct is a product ranked by revenue within its currency; Rank 1 sold most.
+type TopProduct struct {
+	Rank int `json:"rank"`
+	ProductTotals
+}
+
+// ProductSalesQuery selects rows of product_sales_daily_mv. From (inclusive)
+// and To (exclusive) are YYYY-MM-DD UTC dates. Empty filters match
+// everything. Limit caps the rows returned.
+type ProductSalesQuery struct {
+	From        string
+	To          string
+	SKU         string
+	ProductName string
+	Currency    string
+	Limit       int
+}
+
+// where builds the WHERE clause shared by the product queries.
+func (q ProductSalesQuery) where() (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where = append(where, "sale_date >= "+arg(q.From)+"::date", "sale_date < "+arg(q.To)+"::date")
+	if q.SKU != "" {
+		where = append(where, "sku = "+arg(q.SKU))
+	}
+	if q.ProductName != "" {
+		where = append(where, "product_name = "+arg(q.ProductName))
+	}
+	if q.Currency != "" {
+		where = append(where, "currency = "+arg(q.Currency))
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// GetProductSales reads the product_sales_daily_mv materialized view: one
+// row per day, product and currency, newest day first and best-selling
+// product first within a day.
+func (db *DB) GetProductSales(ctx context.Context, q ProductSalesQuery) ([]ProductSales, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("read_product_sales"))
+	defer timer.ObserveDuration()
+
+	where, args := q.where()
+	args = append(args, q.Limit)
+	rows, err := db.Conn.QueryContext(ctx, `
+		SELECT to_char(sale_date, 'YYYY-MM-DD'), sku, product_name, currency,
+		       revenue, units_sold, order_count, avg_order_value
+		FROM product_sales_daily_mv`+where+fmt.Sprintf(`
+		ORDER BY sale_date DESC, currency, revenue DESC, sku, product_name
+		LIMIT $%d`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := []ProductSales{}
+	for rows.Next() {
+		var s ProductSales
+		if err := rows.Scan(&s.Date, &s.SKU, &s.ProductName, &s.Currency,
+			&s.Revenue, &s.UnitsSold, &s.OrderCount, &s.AverageOrderValue); err != nil {
+			return nil, err
+		}
+		sales = append(sales, s)
+	}
+	return sales, rows.Err()
+}
+
+// GetTopProducts ranks products by revenue between q.From and q.To and
+// returns the q.Limit best sellers of each currency: amounts in different
+// currencies are not comparable, so each currency has its own ranking.
+// Ties on revenue are broken by SKU and name so ranks are stable.
+func (db *DB) GetTopProducts(ctx context.Context, q ProductSalesQuery) ([]TopProduct, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("read_top_products"))
+	defer timer.ObserveDuration()
+
+	where, args := q.where()
+	args = append(args, q.Limit)
+	rows, err := db.Conn.QueryContext(ctx, `
+		WITH totals AS (
+			SELECT sku, product_name, currency,
+			       SUM(revenue) AS revenue, SUM(units_sold) AS units_sold, SUM(order_count) AS order_count
+			FROM product_sales_daily_mv`+where+`
+			GROUP BY sku, product_name, currency
+		),
+		ranked AS (
+			SELECT *, row_number() OVER (PARTITION BY currency ORDER BY revenue DESC, sku, product_name) AS rank
+			FROM totals
+		)
+		SELECT rank, sku, product_name, currency, revenue, units_sold, order_count,
+		       round(revenue / order_count, 2)
+		FROM ranked`+fmt.Sprintf(`
+		WHERE rank <= $%d
+		ORDER BY currency, rank`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	top := []TopProduct{}
+	for rows.Next() {
+		var p TopProduct
+		if err := rows.Scan(&p.Rank, &p.SKU, &p.ProductName, &p.Currency,
+			&p.Revenue, &p.UnitsSold, &p.OrderCount, &p.AverageOrderValue); err != nil {
+			return nil, err
+		}
+		top = append(top, p)
+	}
+	return top, rows.Err()
+}
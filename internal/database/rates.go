@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go-polyglot-persistence/internal/metrics"
+	"go-polyglot-persistence/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UpsertExchangeRates inserts rates, replacing any existing rate for the
+// same pair and effective date, in one transaction. Each pair and date may
+// appear only once in rates. It returns the number of rows written.
+func (db *DB) UpsertExchangeRates(ctx context.Context, rates []models.ExchangeRate) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("upsert_rates"))
+	defer timer.ObserveDuration()
+
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for chunk := range slices.Chunk(rates, maxRowsPerInsert) {
+		var query strings.Builder
+		query.WriteString("INSERT INTO exchange_rates (base_currency, quote_currency, effective_date, rate) VALUES ")
+		args := make([]any, 0, len(chunk)*4)
+		for i, r := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4)
+			args = append(args, r.Base, r.Quote, r.EffectiveDate, r.Rate.String())
+		}
+		query.WriteString(` ON CONFLICT (base_currency, quote_currency, effective_date)
+			DO UPDATE SET rate = EXCLUDED.rate, updated_at = now()`)
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// ListExchangeRates returns stored rates, newest first, optionally narrowed
+// to one base and/or quote currency.
+func (db *DB) ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	rows, err := db.Conn.QueryContext(ctx,
+		`SELECT base_currency, quote_currency, to_char(effective_date, 'YYYY-MM-DD'), rate::text
+		 FROM exchange_rates
+		 WHERE ($1 = '' OR base_currency = $1) AND ($2 = '' OR quote_currency = $2)
+		 ORDER BY effective_date DESC, base_currency, quote_currency`,
+		base, quote,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var r models.ExchangeRate
+		var rate string
+		if err := rows.Scan(&r.Base, &r.Quote, &r.EffectiveDate, &rate); err != nil {
+			return nil, err
+		}
+		r.Rate = json.Number(strings.TrimRight(strings.TrimRight(rate, "0"), "."))
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-polyglot-persistence/internal/metrics"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// salesAggregate groups orders and their items into 15-minute sales buckets,
+// exactly as sales_quarter_hour_mv does (migration 0013), leaving out
+// orders that are not sales (see models.OrderStatus.IsSale). Callers append
+// any further condition with AND, and then "GROUP BY 1, 2".
+const salesAggregate = `
+	SELECT date_bin('15 minutes', o.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00') AS bucket_start,
+	       o.currency                     AS currency,
+	       SUM(i.quantity * i.unit_price) AS total_revenue,
+	       SUM(i.quantity)                AS units_sold,
+	       COUNT(DISTINCT o.id)           AS order_count
+	FROM orders o
+	JOIN order_items i ON i.order_id = o.id
+	WHERE o.status NOT IN ('cancelled', 'refunded')`
+
+// salesSource is the relation the sales dashboard reads: the incrementally
+// maintained rollup table, or the materialized view.
+func (db *DB) salesSource() string {
+	if db.IncrementalSales {
+		return "sales_quarter_hour"
+	}
+	return "sales_quarter_hour_mv"
+}
+
+// rollupSales adds the orders with the given IDs to the sales_quarter_hour
+// rollup. It must run in the transaction that inserted them, and only for
+// IDs that transaction actually inserted: the rollup counts each order once,
+// and a replayed order that hit ON CONFLICT DO NOTHING is already counted.
+// Buckets are upserted in key order so concurrent batches lock them in the
+// same order and cannot deadlock on each other.
+func rollupSales(ctx context.Context, tx *sql.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO sales_quarter_hour (bucket_start, currency, total_revenue, units_sold, order_count)`+
+		salesAggregate+`
+		  AND o.id = ANY($1)
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+		ON CONFLICT (bucket_start, currency) DO UPDATE SET
+			total_revenue = sales_quarter_hour.total_revenue + EXCLUDED.total_revenue,
+			units_sold    = sales_quarter_hour.units_sold + EXCLUDED.units_sold,
+			order_count   = sales_quarter_hour.order_count + EXCLUDED.order_count`,
+		pq.Array(ids),
+	)
+	return err
+}
+
+// unrollupSales takes the order with the given ID back out of the
+// sales_quarter_hour rollup when it stops being a sale. It must run in the
+// transaction that changes the order's status, before the change, while
+// salesAggregate still counts the order. A bucket left with no orders is
+// deleted, as reconciliation would.
+func unrollupSales(ctx context.Context, tx *sql.Tx, id string) error {
+	var (
+		bucket    time.Time
+		currency  string
+		remaining int64
+	)
+	err := tx.QueryRowContext(ctx, `
+		UPDATE sales_quarter_hour r SET
+			total_revenue = r.total_revenue - s.total_revenue,
+			units_sold    = r.units_sold - s.units_sold,
+			order_count   = r.order_count - s.order_count
+		FROM (`+salesAggregate+`
+		        AND o.id = $1
+		      GROUP BY 1, 2) s
+		WHERE r.bucket_start = s.bucket_start AND r.currency = s.currency
+		RETURNING r.bucket_start, r.currency, r.order_count`,
+		id,
+	).Scan(&bucket, &currency, &remaining)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil // not counted, e.g. an order without items
+	}
+	if err != nil || remaining > 0 {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM sales_quarter_hour WHERE bucket_start = $1 AND currency = $2 AND order_count = 0",
+		bucket, currency,
+	)
+	return err
+}
+
+// SalesDrift counts the sales_quarter_hour buckets a reconciliation found
+// out of step with orders: Missing buckets had sales but no rollup row,
+// Stale rows had wrong totals and Orphaned rows had no sales at all.
+type SalesDrift struct {
+	Missing  int64
+	Stale    int64
+	Orphaned int64
+}
+
+// Total is the number of drifted buckets.
+func (d SalesDrift) Total() int64 { return d.Missing + d.Stale + d.Orphaned }
+
+// ReconcileSalesRollup rebuilds the sales buckets from orders, compares
+// them with the sales_quarter_hour rollup and repairs any drift.
+//
+// It runs in one REPEATABLE READ transaction, so orders and the rollup are
+// compared in the same snapshot without blocking the worker's inserts. If
+// an insert touches a drifted bucket after the snapshot was taken, the
+// repair fails with a serialization error and is left to the next run;
+// the returned drift is still what was found. Only drifted rows are written,
+// so busy buckets that are correct never cause such a failure.
+func (db *DB) ReconcileSalesRollup(ctx context.Context) (SalesDrift, error) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("reconcile_sales"))
+	defer timer.ObserveDuration()
+
+	var drift SalesDrift
+	tx, err := db.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return drift, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx,
+		"CREATE TEMP TABLE sales_expected ON COMMIT DROP AS"+salesAggregate+" GROUP BY 1, 2",
+	); err != nil {
+		return drift, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE r.bucket_start IS NULL),
+		       COUNT(*) FILTER (WHERE e.bucket_start IS NOT NULL AND r.bucket_start IS NOT NULL
+		                          AND (e.total_revenue, e.units_sold, e.order_count)
+		                              IS DISTINCT FROM (r.total_revenue, r.units_sold, r.order_count)),
+		       COUNT(*) FILTER (WHERE e.bucket_start IS NULL)
+		FROM sales_expected e
+		FULL JOIN sales_quarter_hour r ON r.bucket_start = e.bucket_start AND r.currency = e.currency`,
+	).Scan(&drift.Missing, &drift.Stale, &drift.Orphaned)
+	if err != nil || drift.Total() == 0 {
+		return drift, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM sales_quarter_hour r
+		WHERE NOT EXISTS (
+			SELECT 1 FROM sales_expected e
+			WHERE e.bucket_start = r.bucket_start AND e.currency = r.currency
+		)`,
+	); err != nil {
+		return drift, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO sales_quarter_hour (bucket_start, currency, total_revenue, units_sold, order_count)
+		SELECT e.bucket_start, e.currency, e.total_revenue, e.units_sold, e.order_count
+		FROM sales_expected e
+		LEFT JOIN sales_quarter_hour r ON r.bucket_start = e.bucket_start AND r.currency = e.currency
+		WHERE (e.total_revenue, e.units_sold, e.order_count)
+		      IS DISTINCT FROM (r.total_revenue, r.units_sold, r.order_count)
+		ORDER BY 1, 2
+		ON CONFLICT (bucket_start, currency) DO UPDATE SET
+			total_revenue = EXCLUDED.total_revenue,
+			units_sold    = EXCLUDED.units_sold,
+			order_count   = EXCLUDED.order_count`,
+	); err != nil {
+		return drift, err
+	}
+	return drift, tx.Commit()
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-polyglot-persistence/internal/metrics"
+	"go-polyglot-persistence/internal/models"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Granularity is the length of one dashboard period.
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"  // ISO weeks, starting on Monday
+	GranularityMonth Granularity = "month" // calendar months
+)
+
+// Valid reports whether g is a known granularity.
+func (g Granularity) Valid() bool {
+	switch g {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		return true
+	}
+	return false
+}
+
+// SalesQuery selects the dashboard's rows. From (inclusive) and To
+// (exclusive) are YYYY-MM-DD dates in TZ, an IANA timezone name; callers
+// align them to period boundaries so no period is cut short. Currency is
+// optional: when set, sales are converted into it and summed.
+type SalesQuery struct {
+	From        string
+	To          string
+	Granularity Granularity
+	TZ          string
+	Currency    string
+}
+
+// SalesBucket is one period's sales in one currency. Date is the period's
+// first day in the query's timezone. Converted dashboards have one row per
+// period, in the reporting currency.
+type SalesBucket struct {
+	Date         string       `json:"date"`
+	Currency     string       `json:"currency"`
+	TotalRevenue models.Money `json:"total_revenue"`
+	UnitsSold    int64        `json:"units_sold"`
+	OrderCount   int64        `json:"order_count"`
+}
+
+// salesDays regroups the 15-minute buckets of source (see salesSource) into
+// local days of the query's timezone ($3) from $1 up to $2; $4 is the
+// granularity salesPeriod groups by. An order falls in exactly one bucket,
+// so summing order_count is exact.
+func salesDays(source string) string {
+	return `WITH days AS (
+	SELECT (bucket_start AT TIME ZONE $3::text)::date AS sale_date,
+	       currency,
+	       SUM(total_revenue) AS total_revenue,
+	       SUM(units_sold)    AS units_sold,
+	       SUM(order_count)   AS order_count
+	FROM ` + source + `
+	WHERE bucket_start >= ($1::date::timestamp AT TIME ZONE $3::text)
+	  AND bucket_start <  ($2::date::timestamp AT TIME ZONE $3::text)
+	GROUP BY 1, 2
+)`
+}
+
+// salesPeriod is the first day of the period a sale_date belongs to.
+const salesPeriod = `to_char(date_trunc($4::text, sale_date::timestamp), 'YYYY-MM-DD')`
+
+// GetSales reads the dashboard from the sales_quarter_hour_mv materialized
+// view, or the sales_quarter_hour rollup with IncrementalSales: one row per
+// period and currency, newest period first. With
+// q.Currency set it returns getConvertedSales instead.
+func (db *DB) GetSales(ctx context.Context, q SalesQuery) ([]SalesBucket, error) {
+	if q.Currency != "" {
+		return db.getConvertedSales(ctx, q)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("read_dashboard"))
+	defer timer.ObserveDuration()
+
+	rows, err := db.Conn.QueryContext(ctx, salesDays(db.salesSource())+`
+		SELECT `+salesPeriod+` AS period, currency,
+		       SUM(total_revenue), SUM(units_sold), SUM(order_count)
+		FROM days
+		GROUP BY period, currency
+		ORDER BY period DESC, currency`,
+		q.From, q.To, q.TZ, string(q.Granularity),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sales []SalesBucket
+	for rows.Next() {
+		var s SalesBucket
+		if err := rows.Scan(&s.Date, &s.Currency, &s.TotalRevenue, &s.UnitsSold, &s.OrderCount); err != nil {
+			return nil, err
+		}
+		sales = append(sales, s)
+	}
+	return sales, rows.Err()
+}
+
+// MissingRate is a currency whose sales on Date could not be converted.
+type MissingRate struct {
+	Date     string
+	Currency string
+}
+
+// MissingRatesError is returned by GetSales when some sales have no
+// exchange rate into the reporting currency effective on their date.
+type MissingRatesError struct {
+	Currency string
+	Missing  []MissingRate
+}
+
+func (e *MissingRatesError) Error() string {
+	return fmt.Sprintf("database: %d day/currency pairs have no rate into %s", len(e.Missing), e.Currency)
+}
+
+// getConvertedSales converts every local day's sales into q.Currency and
+// sums them, one row per period. Each currency's daily total is converted
+// with the latest rate effective on or before that day — the
+// currency→target rate, or the inverse of a target→currency rate — so a
+// weekly or monthly total is the sum of correctly converted days.
+// Totals are rounded to the minor unit of q.Currency, so a JPY total has
+// no fractional yen. If any day lacks a rate it returns a
+// *MissingRatesError and no rows: a total that silently leaves out a
+// currency would be wrong.
+func (db *DB) getConvertedSales(ctx context.Context, q SalesQuery) ([]SalesBucket, error) {
+	decimals, ok := models.CurrencyDecimals(q.Currency)
+	if !ok {
+		return nil, fmt.Errorf("database: unsupported currency %q", q.Currency)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("read_dashboard_converted"))
+	defer timer.ObserveDuration()
+
+	rows, err := db.Conn.QueryContext(ctx, salesDays(db.salesSource())+`,
+		converted AS (
+			SELECT d.sale_date, d.currency, d.total_revenue, d.units_sold, d.order_count,
+			       CASE WHEN d.currency = $5::text THEN 1 ELSE r.rate END AS rate
+			FROM days d
+			LEFT JOIN LATERAL (
+				SELECT rate FROM (
+					SELECT rate, effective_date FROM exchange_rates
+					WHERE base_currency = d.currency AND quote_currency = $5::text AND effective_date <= d.sale_date
+					UNION ALL
+					SELECT 1 / rate, effective_date FROM exchange_rates
+					WHERE base_currency = $5::text AND quote_currency = d.currency AND effective_date <= d.sale_date
+				) candidates
+				ORDER BY effective_date DESC
+				LIMIT 1
+			) r ON true
+		)
+		SELECT `+salesPeriod+` AS period,
+		       round(COALESCE(SUM(total_revenue * rate), 0), $6::int),
+		       SUM(units_sold),
+		       SUM(order_count),
+		       COALESCE(array_agg(to_char(sale_date, 'YYYY-MM-DD') || '/' || currency
+		                          ORDER BY sale_date, currency) FILTER (WHERE rate IS NULL), '{}')
+		FROM converted
+		GROUP BY period
+		ORDER BY period DESC`,
+		q.From, q.To, q.TZ, string(q.Granularity), q.Currency, decimals,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		sales   []SalesBucket
+		missing []MissingRate
+	)
+	for rows.Next() {
+		s := SalesBucket{Currency: q.Currency}
+		var unconverted []string // "YYYY-MM-DD/CUR"
+		if err := rows.Scan(&s.Date, &s.TotalRevenue, &s.UnitsSold, &s.OrderCount, pq.Array(&unconverted)); err != nil {
+			return nil, err
+		}
+		for _, u := range unconverted {
+			date, currency, _ := strings.Cut(u, "/")
+			missing = append(missing, MissingRate{Date: date, Currency: currency})
+		}
+		sales = append(sales, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, &MissingRatesError{Currency: q.Currency, Missing: missing}
+	}
+	return sales, nil
+}
@@ -16,3 +16,81 @@ var DBQueryDuration = promauto.NewHistogramVec(
 	},
 	[]string{"operation"},
 )
+
+// MessagesDeadLettered counts messages parked in the dead-letter queue.
+// The 'reason' label distinguishes exhausted retries from discarded payloads.
+var MessagesDeadLettered = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "queue_messages_dead_lettered_total",
+		Help: "Number of messages moved to the dead-letter queue",
+	},
+	[]string{"reason"},
+)
+
+// QueueReconnects counts re-established RabbitMQ connections.
+// The 'side' label is "publisher" (api) or "consumer" (worker).
+var QueueReconnects = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "queue_reconnects_total",
+		Help: "Number of times the RabbitMQ connection was re-established",
+	},
+	[]string{"side"},
+)
+
+// SalesRollupDrift is the number of sales_quarter_hour buckets the last
+// reconciliation found out of step with orders, by 'kind': "missing",
+// "stale" or "orphaned". Anything but zero means an insert path skipped
+// the rollup; the reconciliation has already repaired it.
+var SalesRollupDrift = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sales_rollup_drift_buckets",
+		Help: "Drifted sales rollup buckets found by the last reconciliation",
+	},
+	[]string{"kind"},
+)
+
+// SearchDrift is the number of orders-index documents the last search
+// reconciliation found out of step with Postgres, by 'kind': "missing",
+// "stale" or "orphaned". The reconciliation has already repaired them;
+// anything but zero means a write reached Postgres but not Elasticsearch,
+// or the other way round.
+var SearchDrift = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "search_drift_documents",
+		Help: "Drifted search documents found by the last reconciliation",
+	},
+	[]string{"kind"},
+)
+
+// CronLeader is 1 on the replica that currently runs the cron jobs and 0 on
+// the others. The 'replica' label is REPLICA_ID; summed across replicas it
+// should be exactly 1, briefly 0 during a failover.
+var CronLeader = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "cron_leader",
+		Help: "Whether this replica holds cron leadership (1) or not (0)",
+	},
+	[]string{"replica"},
+)
+
+// CronLeadershipChanges counts leadership gained and lost by this replica.
+// The 'event' label is "acquired" or "lost"; frequent changes point at a
+// flapping Postgres connection.
+var CronLeadershipChanges = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cron_leadership_changes_total",
+		Help: "Number of times this replica acquired or lost cron leadership",
+	},
+	[]string{"event"},
+)
+
+// JobRunDuration measures background job runs, labelled by 'job' and by
+// 'status' ("succeeded" or "failed"). Its count is the number of runs.
+var JobRunDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "job_run_duration_seconds",
+		Help:    "Duration of background job runs in seconds",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900},
+	},
+	[]string{"job", "status"},
+)
@@ -0,0 +1,395 @@
+// Package migrations versions the Postgres schema.
+//
+// Migrations are plain SQL files embedded in every binary, named
+// NNNN_description.up.sql / NNNN_description.down.sql. Applied versions are
+// recorded in schema_migrations. Each migration runs in its own transaction
+// together with its schema_migrations row, so a failure leaves the database
+// at the previous version rather than half-migrated. Statements that cannot
+// run inside a transaction (CREATE INDEX CONCURRENTLY, REFRESH ... CONCURRENTLY)
+// therefore do not belong in a migration.
+//
+// A session-level advisory lock serialises migrators, so the API, the worker
+// and cmd/migrate can all start at once against the same database.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is the pg_advisory_lock key held while migrating.
+// Arbitrary, but must never change — old and new binaries must contend on it.
+const lockKey int64 = 7_311_042_815
+
+// ErrUnknownVersion is returned when a requested or applied version has no
+// embedded migration, e.g. a database migrated by a newer binary.
+var ErrUnknownVersion = errors.New("migrations: unknown version")
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// Status describes one migration and whether it has been applied.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator applies the embedded migrations to one database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration // sorted by Version
+}
+
+// New parses the embedded migrations. It fails if a version is missing its
+// up or down file, or is used twice.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending embedded migration to db. It is what the API and
+// worker run at startup when MIGRATE_ON_START is set.
+func Up(ctx context.Context, db *sql.DB) error {
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
+	return m.Up(ctx)
+}
+
+// load reads every sql/*.sql file in fsys into sorted Migrations.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		file := e.Name()
+
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migrations: %s: want NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+		num, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: %s: missing _name", file)
+		}
+		version, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrations: %s: invalid version %q", file, num)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join("sql", file))
+		if err != nil {
+			return nil, fmt.Errorf("migrations: %w", err)
+		}
+
+		m, seen := byVersion[version]
+		if !seen {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migrations: version %d used by both %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return int(a.Version - b.Version)
+	})
+	return migrations, nil
+}
+
+// Latest returns the highest embedded version.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration. Versions applied by a newer binary are
+// logged and left alone, so an older replica can still start during a rollout.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for v := range applied {
+			if _, ok := m.find(v); !ok {
+				slog.Warn("database has a migration this binary does not know",
+					"component", "migrate",
+					"version", v,
+				)
+			}
+		}
+		return m.migrate(ctx, conn, applied, m.Latest())
+	})
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			slog.Info("no migrations to roll back", "component", "migrate")
+			return nil
+		}
+		var current int64
+		for v := range applied {
+			current = max(current, v)
+		}
+		mig, ok := m.find(current)
+		if !ok {
+			return fmt.Errorf("%w: %d is applied but not embedded", ErrUnknownVersion, current)
+		}
+		return m.rollback(ctx, conn, mig)
+	})
+}
+
+// To migrates up or down until version is the highest applied migration.
+// To(ctx, 0) rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for v := range applied {
+			if _, ok := m.find(v); !ok && v > version {
+				return fmt.Errorf("%w: %d is applied but not embedded", ErrUnknownVersion, v)
+			}
+		}
+		return m.migrate(ctx, conn, applied, version)
+	})
+}
+
+// migrate rolls back applied migrations above version, newest first, then
+// applies pending ones up to version, oldest first.
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, applied map[int64]bool, version int64) error {
+	rolledBack, pending := 0, 0
+	for _, mig := range slices.Backward(m.migrations) {
+		if mig.Version > version && applied[mig.Version] {
+			if err := m.rollback(ctx, conn, mig); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+	}
+	for _, mig := range m.migrations {
+		if mig.Version <= version && !applied[mig.Version] {
+			if err := m.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+			pending++
+		}
+	}
+
+	slog.Info("schema migrated",
+		"component", "migrate",
+		"version", version,
+		"applied", pending,
+		"rolled_back", rolledBack,
+	)
+	return nil
+}
+
+// Status lists every embedded migration with its applied time, if any.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		appliedAt := map[int64]time.Time{}
+		for rows.Next() {
+			var v int64
+			var at time.Time
+			if err := rows.Scan(&v, &at); err != nil {
+				return err
+			}
+			appliedAt[v] = at
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if at, ok := appliedAt[mig.Version]; ok {
+				s.AppliedAt = &at
+			}
+			out = append(out, s)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock.
+// The advisory lock is session-scoped, so it must be taken, used and released
+// on the same connection rather than through the pool.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrations: acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("migrations: acquire lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context: ctx may already be cancelled, and a lock left
+		// behind on a pooled connection would block every later migrator.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			slog.Error("release migration lock failed", "component", "migrate", "error", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT      PRIMARY KEY,
+			name       TEXT        NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return fmt.Errorf("migrations: create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	start := time.Now()
+	err := inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+			mig.Version, mig.Name,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migrations: apply %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	slog.Info("migration applied",
+		"component", "migrate",
+		"version", mig.Version,
+		"name", mig.Name,
+		"duration", time.Since(start),
+	)
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	start := time.Now()
+	err := inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, mig.down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migrations: roll back %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	slog.Info("migration rolled back",
+		"component", "migrate",
+		"version", mig.Version,
+		"name", mig.Name,
+		"duration", time.Since(start),
+	)
+	return nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	i, ok := slices.BinarySearchFunc(m.migrations, version, func(mig Migration, v int64) int {
+		return int(mig.Version - v)
+	})
+	if !ok {
+		return Migration{}, false
+	}
+	return m.migrations[i], true
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]bool{}
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_tenth.up.sql":    {Data: []byte("up 10")},
+		"sql/0010_tenth.down.sql":  {Data: []byte("down 10")},
+		"sql/0002_second.up.sql":   {Data: []byte("up 2")},
+		"sql/0002_second.down.sql": {Data: []byte("down 2")},
+		"sql/0001_init.up.sql":     {Data: []byte("up 1")},
+		"sql/0001_init.down.sql":   {Data: []byte("down 1")},
+	}
+	got, err := load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "init", up: "up 1", down: "down 1"},
+		{Version: 2, Name: "second", up: "up 2", down: "down 2"},
+		{Version: 10, Name: "tenth", up: "up 10", down: "down 10"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{name: "missing down", files: []string{"0001_init.up.sql"}, want: "needs both up and down"},
+		{name: "missing up", files: []string{"0001_init.down.sql"}, want: "needs both up and down"},
+		{name: "no direction", files: []string{"0001_init.sql"}, want: "want NNNN_name.up.sql"},
+		{name: "bad direction", files: []string{"0001_init.sideways.sql"}, want: "want NNNN_name.up.sql"},
+		{name: "no name", files: []string{"0001.up.sql"}, want: "missing _name"},
+		{name: "version zero", files: []string{"0000_init.up.sql"}, want: "invalid version"},
+		{name: "non-numeric version", files: []string{"abc_init.up.sql"}, want: "invalid version"},
+		{
+			name:  "version reused",
+			files: []string{"0001_init.up.sql", "0001_init.down.sql", "0001_other.up.sql", "0001_other.down.sql"},
+			want:  "used by both",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, f := range tt.files {
+				fsys["sql/"+f] = &fstest.MapFile{Data: []byte("SELECT 1")}
+			}
+			_, err := load(fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("load(%v) = %v, want an error containing %q", tt.files, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestEmbedded keeps a malformed file from reaching a binary.
+func TestEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d has version %d; versions must be contiguous", i, m.Version)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP MATERIALIZED VIEW IF EXISTS daily_sales_mv;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,29 @@
+-- Baseline: the schema the original init.sql created.
+-- Written with IF NOT EXISTS so it also applies cleanly to databases that
+-- were bootstrapped by init.sql before migrations existed.
+
+-- 'id' is a UUID string generated by the application layer (uuid.New()).
+-- ON CONFLICT DO NOTHING in the worker INSERT makes replay idempotent.
+CREATE TABLE IF NOT EXISTS orders (
+    id           TEXT        PRIMARY KEY,
+    product_name TEXT        NOT NULL,
+    amount       NUMERIC     NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Aggregates daily revenue. Refreshed hourly by the cron scheduler.
+-- CONCURRENTLY means reads are not blocked during refresh (requires a unique index).
+CREATE MATERIALIZED VIEW IF NOT EXISTS daily_sales_mv AS
+    SELECT
+        created_at::DATE AS sale_date,
+        SUM(amount)      AS total_revenue
+    FROM orders
+    GROUP BY sale_date
+WITH NO DATA;  -- populated by the REFRESH below, not at creation time
+
+-- Required for REFRESH MATERIALIZED VIEW CONCURRENTLY.
+CREATE UNIQUE INDEX IF NOT EXISTS daily_sales_mv_date_idx ON daily_sales_mv (sale_date);
+
+-- A view created WITH NO DATA cannot be refreshed CONCURRENTLY until it has
+-- been populated once, and this plain REFRESH is allowed inside a transaction.
+REFRESH MATERIALIZED VIEW daily_sales_mv;
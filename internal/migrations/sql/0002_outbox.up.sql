@@ -0,0 +1,13 @@
+-- In outbox mode (ORDER_WRITE_MODE=outbox) the API commits the order here
+-- instead of publishing directly. A relay publishes unsent rows to RabbitMQ
+-- and stamps sent_at, giving at-least-once delivery without a dual write.
+CREATE TABLE IF NOT EXISTS outbox (
+    id           BIGSERIAL   PRIMARY KEY,
+    aggregate_id TEXT        NOT NULL,
+    payload      JSONB       NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at      TIMESTAMPTZ
+);
+
+-- The relay only ever scans unsent rows, oldest first.
+CREATE INDEX IF NOT EXISTS outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL;
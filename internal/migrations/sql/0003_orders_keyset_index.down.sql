@@ -0,0 +1 @@
+DROP INDEX IF EXISTS orders_created_at_id_idx;
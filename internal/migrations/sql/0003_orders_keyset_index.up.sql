@@ -0,0 +1,2 @@
+-- Keyset pagination for GET /api/orders walks (created_at, id) in either direction.
+CREATE INDEX IF NOT EXISTS orders_created_at_id_idx ON orders (created_at, id);
@@ -0,0 +1,14 @@
+-- 'status' follows the lifecycle in models.OrderStatus; transitions are
+-- applied only by the worker, so Postgres, Redis and ES converge.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'confirmed', 'cancelled', 'refunded'));
+
+-- Existing rows get updated_at = created_at rather than the migration time.
+-- Adding the column nullable first keeps this step idempotent on databases
+-- that already have it.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+UPDATE orders SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE orders
+    ALTER COLUMN updated_at SET DEFAULT now(),
+    ALTER COLUMN updated_at SET NOT NULL;
@@ -0,0 +1,22 @@
+-- Collapses each order back to the product name of its first line.
+ALTER TABLE orders ADD COLUMN product_name TEXT;
+UPDATE orders o SET product_name = i.product_name
+    FROM order_items i
+    WHERE i.order_id = o.id AND i.line = 1;
+UPDATE orders SET product_name = '' WHERE product_name IS NULL;
+ALTER TABLE orders ALTER COLUMN product_name SET NOT NULL;
+
+DROP MATERIALIZED VIEW daily_sales_mv;
+
+CREATE MATERIALIZED VIEW daily_sales_mv AS
+    SELECT
+        created_at::DATE AS sale_date,
+        SUM(amount)      AS total_revenue
+    FROM orders
+    GROUP BY sale_date
+WITH NO DATA;
+
+CREATE UNIQUE INDEX daily_sales_mv_date_idx ON daily_sales_mv (sale_date);
+REFRESH MATERIALIZED VIEW daily_sales_mv;
+
+DROP TABLE order_items;
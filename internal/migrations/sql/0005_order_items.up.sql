@@ -0,0 +1,39 @@
+-- Orders become a set of line items. orders.amount stays as the order total,
+-- computed by the application from its items when the order is created.
+CREATE TABLE order_items (
+    order_id     TEXT    NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    line         INT     NOT NULL,  -- 1-based position within the order
+    sku          TEXT    NOT NULL,
+    product_name TEXT    NOT NULL,
+    quantity     INT     NOT NULL CHECK (quantity > 0),
+    unit_price   NUMERIC NOT NULL CHECK (unit_price >= 0),
+    PRIMARY KEY (order_id, line)
+);
+
+-- GET /api/orders?product= and ?sku= filter orders by their items.
+CREATE INDEX order_items_product_name_idx ON order_items (product_name);
+CREATE INDEX order_items_sku_idx ON order_items (sku);
+
+-- Every existing order becomes a single line of quantity 1. Those orders
+-- predate SKUs, so the line's SKU is empty.
+INSERT INTO order_items (order_id, line, sku, product_name, quantity, unit_price)
+    SELECT id, 1, '', product_name, 1, amount FROM orders;
+
+-- Revenue is now aggregated over items, which also yields units sold.
+DROP MATERIALIZED VIEW daily_sales_mv;
+
+CREATE MATERIALIZED VIEW daily_sales_mv AS
+    SELECT
+        o.created_at::DATE               AS sale_date,
+        SUM(i.quantity * i.unit_price)   AS total_revenue,
+        SUM(i.quantity)                  AS units_sold,
+        COUNT(DISTINCT o.id)             AS order_count
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY sale_date
+WITH NO DATA;
+
+CREATE UNIQUE INDEX daily_sales_mv_date_idx ON daily_sales_mv (sale_date);
+REFRESH MATERIALIZED VIEW daily_sales_mv;
+
+ALTER TABLE orders DROP COLUMN product_name;
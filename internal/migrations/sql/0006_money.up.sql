@@ -0,0 +1,27 @@
+-- Money columns get a fixed scale of two decimals, matching models.Money.
+-- Existing values were validated to two decimals, so the rounding is a no-op
+-- except for float artefacts such as 59.970000000000006.
+--
+-- daily_sales_mv depends on unit_price, so it is dropped around the type change.
+DROP MATERIALIZED VIEW daily_sales_mv;
+
+ALTER TABLE orders      ALTER COLUMN amount     TYPE NUMERIC(14, 2) USING round(amount, 2);
+ALTER TABLE order_items ALTER COLUMN unit_price TYPE NUMERIC(14, 2) USING round(unit_price, 2);
+
+-- Every amount on an order, including its items' unit prices, is in this currency.
+ALTER TABLE orders
+    ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+
+CREATE MATERIALIZED VIEW daily_sales_mv AS
+    SELECT
+        o.created_at::DATE               AS sale_date,
+        SUM(i.quantity * i.unit_price)   AS total_revenue,
+        SUM(i.quantity)                  AS units_sold,
+        COUNT(DISTINCT o.id)             AS order_count
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY sale_date
+WITH NO DATA;
+
+CREATE UNIQUE INDEX daily_sales_mv_date_idx ON daily_sales_mv (sale_date);
+REFRESH MATERIALIZED VIEW daily_sales_mv;
@@ -0,0 +1,17 @@
+DROP MATERIALIZED VIEW daily_sales_mv;
+
+CREATE MATERIALIZED VIEW daily_sales_mv AS
+    SELECT
+        o.created_at::DATE               AS sale_date,
+        SUM(i.quantity * i.unit_price)   AS total_revenue,
+        SUM(i.quantity)                  AS units_sold,
+        COUNT(DISTINCT o.id)             AS order_count
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY sale_date
+WITH NO DATA;
+
+CREATE UNIQUE INDEX daily_sales_mv_date_idx ON daily_sales_mv (sale_date);
+REFRESH MATERIALIZED VIEW daily_sales_mv;
+
+DROP TABLE exchange_rates;
@@ -0,0 +1,31 @@
+-- One unit of base_currency is worth rate units of quote_currency from
+-- effective_date until the pair's next row. Converting a sale uses the
+-- latest rate effective on or before its date, in either direction.
+CREATE TABLE exchange_rates (
+    base_currency  TEXT            NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    quote_currency TEXT            NOT NULL CHECK (quote_currency ~ '^[A-Z]{3}$'),
+    effective_date DATE            NOT NULL,
+    rate           NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    updated_at     TIMESTAMPTZ     NOT NULL DEFAULT now(),
+    PRIMARY KEY (base_currency, quote_currency, effective_date),
+    CHECK (base_currency <> quote_currency)
+);
+
+-- Summing amounts across currencies is meaningless, so the view keeps one
+-- row per day and currency. Conversion happens at read time.
+DROP MATERIALIZED VIEW daily_sales_mv;
+
+CREATE MATERIALIZED VIEW daily_sales_mv AS
+    SELECT
+        o.created_at::DATE               AS sale_date,
+        o.currency                       AS currency,
+        SUM(i.quantity * i.unit_price)   AS total_revenue,
+        SUM(i.quantity)                  AS units_sold,
+        COUNT(DISTINCT o.id)             AS order_count
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY sale_date, o.currency
+WITH NO DATA;
+
+CREATE UNIQUE INDEX daily_sales_mv_date_currency_idx ON daily_sales_mv (sale_date, currency);
+REFRESH MATERIALIZED VIEW daily_sales_mv;
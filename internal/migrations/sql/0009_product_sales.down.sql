@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW product_sales_daily_mv;
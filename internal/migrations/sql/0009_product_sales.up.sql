@@ -0,0 +1,26 @@
+-- Per-product daily rollup for the product dashboards. A product is a
+-- (sku, product_name) pair, since lines backfilled from before order_items
+-- have an empty sku. Days are UTC days. An order containing a product
+-- counts once towards that product's order_count, however many lines it
+-- has for it, so avg_order_value is the product's revenue per such order.
+CREATE MATERIALIZED VIEW product_sales_daily_mv AS
+    SELECT
+        (o.created_at AT TIME ZONE 'UTC')::DATE                            AS sale_date,
+        o.currency                                                         AS currency,
+        i.sku                                                              AS sku,
+        i.product_name                                                     AS product_name,
+        SUM(i.quantity * i.unit_price)                                     AS revenue,
+        SUM(i.quantity)                                                    AS units_sold,
+        COUNT(DISTINCT o.id)                                               AS order_count,
+        round(SUM(i.quantity * i.unit_price) / COUNT(DISTINCT o.id), 2)    AS avg_order_value
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY sale_date, o.currency, i.sku, i.product_name
+WITH NO DATA;
+
+-- Required for REFRESH MATERIALIZED VIEW CONCURRENTLY; also serves the
+-- dashboards' range scan on sale_date.
+CREATE UNIQUE INDEX product_sales_daily_mv_key_idx
+    ON product_sales_daily_mv (sale_date, currency, sku, product_name);
+
+REFRESH MATERIALIZED VIEW product_sales_daily_mv;
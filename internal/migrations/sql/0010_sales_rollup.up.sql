@@ -0,0 +1,23 @@
+-- Incrementally maintained twin of sales_quarter_hour_mv, used when
+-- SALES_AGGREGATION=incremental: every newly inserted order is added to its
+-- bucket in the inserting transaction, so the dashboard is never stale.
+-- It is kept in both modes' schema so switching modes needs no migration.
+CREATE TABLE sales_quarter_hour (
+    bucket_start  TIMESTAMPTZ   NOT NULL,
+    currency      TEXT          NOT NULL,
+    total_revenue NUMERIC(20,2) NOT NULL,
+    units_sold    BIGINT        NOT NULL,
+    order_count   BIGINT        NOT NULL,
+    PRIMARY KEY (bucket_start, currency)
+);
+
+INSERT INTO sales_quarter_hour (bucket_start, currency, total_revenue, units_sold, order_count)
+    SELECT
+        date_bin('15 minutes', o.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00'),
+        o.currency,
+        SUM(i.quantity * i.unit_price),
+        SUM(i.quantity),
+        COUNT(DISTINCT o.id)
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY 1, 2;
@@ -0,0 +1,21 @@
+-- One row per run of a scheduled job, whichever replica ran it and however
+-- it was triggered. A run is inserted as 'running' before the job starts and
+-- finished with its outcome; a run whose replica died is marked 'abandoned'
+-- once its deadline has passed.
+CREATE TABLE job_runs (
+    id          BIGSERIAL   PRIMARY KEY,
+    job         TEXT        NOT NULL,
+    trigger     TEXT        NOT NULL CHECK (trigger IN ('schedule', 'manual', 'election')),
+    replica     TEXT        NOT NULL,
+    status      TEXT        NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'abandoned')),
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deadline    TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    error       TEXT
+);
+
+-- At most one run of a job at a time, across all replicas.
+CREATE UNIQUE INDEX job_runs_running_idx ON job_runs (job) WHERE status = 'running';
+
+-- Run history, newest first.
+CREATE INDEX job_runs_job_started_idx ON job_runs (job, started_at DESC);
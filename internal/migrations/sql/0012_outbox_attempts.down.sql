@@ -0,0 +1,7 @@
+DROP INDEX outbox_unsent_idx;
+CREATE INDEX outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL;
+
+ALTER TABLE outbox
+    DROP COLUMN parked_at,
+    DROP COLUMN last_error,
+    DROP COLUMN attempts;
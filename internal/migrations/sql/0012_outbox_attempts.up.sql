@@ -0,0 +1,11 @@
+-- The relay records every failed publish on its row and parks the row once
+-- it has failed OUTBOX_MAX_ATTEMPTS times, so one row that can never be
+-- published does not hold up the rows behind it.
+ALTER TABLE outbox
+    ADD COLUMN attempts   INT         NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN parked_at  TIMESTAMPTZ;
+
+-- The relay only ever scans unsent rows that are not parked, oldest first.
+DROP INDEX outbox_unsent_idx;
+CREATE INDEX outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL AND parked_at IS NULL;
@@ -0,0 +1,18 @@
+DROP MATERIALIZED VIEW sales_quarter_hour_mv;
+
+CREATE MATERIALIZED VIEW sales_quarter_hour_mv AS
+    SELECT
+        date_bin('15 minutes', o.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00') AS bucket_start,
+        o.currency                                                                 AS currency,
+        SUM(i.quantity * i.unit_price)                                             AS total_revenue,
+        SUM(i.quantity)                                                            AS units_sold,
+        COUNT(DISTINCT o.id)                                                       AS order_count
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY bucket_start, o.currency
+WITH NO DATA;
+
+CREATE UNIQUE INDEX sales_quarter_hour_mv_bucket_currency_idx
+    ON sales_quarter_hour_mv (bucket_start, currency);
+
+REFRESH MATERIALIZED VIEW sales_quarter_hour_mv;
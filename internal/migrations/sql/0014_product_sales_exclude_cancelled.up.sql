@@ -0,0 +1,24 @@
+-- Cancelled and refunded orders are not sales: product_sales_daily_mv is
+-- rebuilt without them, like sales_quarter_hour_mv in 0013.
+DROP MATERIALIZED VIEW product_sales_daily_mv;
+
+CREATE MATERIALIZED VIEW product_sales_daily_mv AS
+    SELECT
+        (o.created_at AT TIME ZONE 'UTC')::DATE                            AS sale_date,
+        o.currency                                                         AS currency,
+        i.sku                                                              AS sku,
+        i.product_name                                                     AS product_name,
+        SUM(i.quantity * i.unit_price)                                     AS revenue,
+        SUM(i.quantity)                                                    AS units_sold,
+        COUNT(DISTINCT o.id)                                               AS order_count,
+        round(SUM(i.quantity * i.unit_price) / COUNT(DISTINCT o.id), 2)    AS avg_order_value
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    WHERE o.status NOT IN ('cancelled', 'refunded')
+    GROUP BY sale_date, o.currency, i.sku, i.product_name
+WITH NO DATA;
+
+CREATE UNIQUE INDEX product_sales_daily_mv_key_idx
+    ON product_sales_daily_mv (sale_date, currency, sku, product_name);
+
+REFRESH MATERIALIZED VIEW product_sales_daily_mv;
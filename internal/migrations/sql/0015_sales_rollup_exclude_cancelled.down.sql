@@ -0,0 +1,12 @@
+DELETE FROM sales_quarter_hour;
+
+INSERT INTO sales_quarter_hour (bucket_start, currency, total_revenue, units_sold, order_count)
+    SELECT
+        date_bin('15 minutes', o.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00'),
+        o.currency,
+        SUM(i.quantity * i.unit_price),
+        SUM(i.quantity),
+        COUNT(DISTINCT o.id)
+    FROM orders o
+    JOIN order_items i ON i.order_id = o.id
+    GROUP BY 1, 2;
@@ -0,0 +1,43 @@
+package models
+
+import "encoding/json"
+
+// currencyDecimals lists the ISO 4217 currencies orders may be priced in,
+// with the number of decimals each one's minor unit has. Money has a fixed
+// scale of two, so currencies with three-decimal minor units (BHD, KWD, ...)
+// cannot be represented and are deliberately absent.
+var currencyDecimals = map[string]int{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2, "PHP": 2, "PLN": 2, "RON": 2,
+	"SEK": 2, "SGD": 2, "THB": 2, "TRY": 2, "USD": 2, "ZAR": 2,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "VND": 0,
+}
+
+// CurrencyDecimals returns how many decimals amounts in currency may have,
+// and false if the currency is not supported.
+func CurrencyDecimals(currency string) (int, bool) {
+	n, ok := currencyDecimals[currency]
+	return n, ok
+}
+
+// HasDecimals reports whether m has at most n decimals (n is 0, 1 or 2).
+func (m Money) HasDecimals(n int) bool {
+	switch n {
+	case 0:
+		return m%moneyScale == 0
+	case 1:
+		return m%10 == 0
+	}
+	return true
+}
+
+// ExchangeRate says one unit of Base was worth Rate units of Quote from
+// EffectiveDate (YYYY-MM-DD) until the pair's next rate takes effect.
+// Rate is kept as its decimal text so it is stored without rounding.
+type ExchangeRate struct {
+	Base          string      `json:"base"`
+	Quote         string      `json:"quote"`
+	EffectiveDate string      `json:"effective_date"`
+	Rate          json.Number `json:"rate"`
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 code of orders that do not name one.
+const DefaultCurrency = "USD"
+
+// Money is an exact amount in minor units: hundredths of the currency unit.
+// It never passes through float64, so sums and products are exact.
+//
+// On the wire it is a plain JSON number with at most two decimals (12.5,
+// 1299.99), so clients see the same shape float amounts had. In Postgres it
+// maps to NUMERIC(14,2) and is read and written as decimal text.
+type Money int64
+
+// moneyScale is the number of minor units in one major unit.
+const moneyScale = 100
+
+// ErrMoneyPrecision is returned when a decimal has more than two decimal places.
+var ErrMoneyPrecision = errors.New("models: money has more than 2 decimal places")
+
+// ErrMoneyRange is returned when a decimal does not fit in Money.
+var ErrMoneyRange = errors.New("models: money out of range")
+
+// ParseMoney parses a decimal such as "12", "-0.5" or "1299.99" exactly.
+// Exponents, more than two decimal places and non-numeric input are errors.
+func ParseMoney(s string) (Money, error) {
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+
+	whole, frac, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || (hasPoint && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("models: invalid money %q", s)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > 2 {
+		return 0, ErrMoneyPrecision
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/moneyScale-1 {
+		return 0, ErrMoneyRange
+	}
+	cents := 0
+	if frac != "" {
+		cents, _ = strconv.Atoi(frac + strings.Repeat("0", 2-len(frac)))
+	}
+
+	m := Money(units*moneyScale + int64(cents))
+	if neg {
+		m = -m
+	}
+	return m, nil
+}
+
+// roundMoney parses a decimal like ParseMoney but rounds it to two decimal
+// places, half away from zero, instead of rejecting the extra ones. It is
+// for amounts that passed through float64 before Money existed, such as the
+// 59.970000000000006 an older release could sum a cart to.
+func roundMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("models: invalid money %q", s)
+	}
+	r.Mul(r, big.NewRat(moneyScale, 1))
+
+	cents, rem := new(big.Int).QuoRem(new(big.Int).Abs(r.Num()), r.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		cents.Add(cents, big.NewInt(1))
+	}
+	if !cents.IsInt64() || cents.Int64() > math.MaxInt64-moneyScale {
+		return 0, ErrMoneyRange
+	}
+
+	m := Money(cents.Int64())
+	if r.Sign() < 0 {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats m as a decimal with exactly two places, e.g. "1299.90".
+func (m Money) String() string {
+	sign := ""
+	n := int64(m)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/moneyScale, n%moneyScale)
+}
+
+// Mul returns m multiplied by n.
+func (m Money) Mul(n int) Money { return m * Money(n) }
+
+// MarshalJSON writes m as a JSON number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one, without going
+// through float64. Only this service's own payloads are decoded this way
+// (client input is validated with ParseMoney), and queued messages, outbox
+// rows, cache entries and documents from older releases may hold float
+// artefacts, so more than two decimals are rounded rather than rejected.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseMoney(s)
+	if errors.Is(err, ErrMoneyPrecision) {
+		parsed, err = roundMoney(s)
+	}
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns, which lib/pq returns as text.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.scanText(string(v))
+	case string:
+		return m.scanText(v)
+	case int64:
+		*m = Money(v * moneyScale)
+		return nil
+	case nil:
+		return errors.New("models: cannot scan NULL into Money")
+	default:
+		return fmt.Errorf("models: cannot scan %T into Money", src)
+	}
+}
+
+func (m *Money) scanText(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer. The decimal text is cast to NUMERIC by
+// Postgres, so no precision is lost on the way in.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+		errIs   error
+	}{
+		{in: "0", want: 0},
+		{in: "12", want: 1200},
+		{in: "12.5", want: 1250},
+		{in: "1299.99", want: 129999},
+		{in: "-0.5", want: -50},
+		{in: "0.01", want: 1},
+		{in: "3.100", want: 310},
+		{in: "59.970000000000006", wantErr: true, errIs: ErrMoneyPrecision},
+		{in: "1.001", wantErr: true, errIs: ErrMoneyPrecision},
+		{in: "92233720368547758.07", wantErr: true, errIs: ErrMoneyRange},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "5.", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "+1", wantErr: true},
+		{in: "1,50", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil || (tt.errIs != nil && !errors.Is(err, tt.errIs)) {
+				t.Errorf("ParseMoney(%q) = %d, %v; want error %v", tt.in, got, err, tt.errIs)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{1250, "12.50"},
+		{129999, "1299.99"},
+		{-50, "-0.50"},
+		{-129905, "-1299.05"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    Money
+		wantErr bool
+	}{
+		{src: []byte("59.97"), want: 5997},
+		{src: "0.10", want: 10},
+		{src: int64(7), want: 700},
+		{src: []byte("1.005"), wantErr: true},
+		{src: nil, wantErr: true},
+		{src: 1.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := m.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %d, want an error", tt.src, m)
+			}
+			continue
+		}
+		if err != nil || m != tt.want {
+			t.Errorf("Scan(%#v) = %d, %v; want %d", tt.src, m, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: `12.5`, want: 1250},
+		{in: `"12.50"`, want: 1250},
+		{in: `59.970000000000006`, want: 5997},
+		{in: `0.1049999`, want: 10},
+		{in: `0.105`, want: 11},
+		{in: `-0.105`, want: -11},
+		{in: `"abc"`, wantErr: true},
+		{in: `null`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := json.Unmarshal([]byte(tt.in), &m)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %d, want an error", tt.in, m)
+			}
+			continue
+		}
+		if err != nil || m != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, %v; want %d", tt.in, m, err, tt.want)
+		}
+
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		if err := json.Unmarshal(out, &back); err != nil || back != m {
+			t.Errorf("round trip of %d via %s = %d, %v", m, out, back, err)
+		}
+	}
+}
+
+func TestOrderUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Order
+	}{
+		{
+			name: "line items",
+			in: `{"id":"o1","items":[{"sku":"A","product_name":"Laptop","quantity":2,"unit_price":19.99}],
+				"amount":39.98,"currency":"EUR","status":"pending"}`,
+			want: Order{
+				ID:       "o1",
+				Items:    []OrderItem{{SKU: "A", ProductName: "Laptop", Quantity: 2, UnitPrice: 1999}},
+				Amount:   3998,
+				Currency: "EUR",
+				Status:   StatusPending,
+			},
+		},
+		{
+			name: "line items with a float total",
+			in: `{"id":"o2","items":[{"sku":"A","product_name":"Pen","quantity":3,"unit_price":19.99}],
+				"amount":59.970000000000006}`,
+			want: Order{
+				ID:       "o2",
+				Items:    []OrderItem{{SKU: "A", ProductName: "Pen", Quantity: 3, UnitPrice: 1999}},
+				Amount:   5997,
+				Currency: DefaultCurrency,
+			},
+		},
+		{
+			name: "single product",
+			in:   `{"id":"o3","product_name":"Mouse","amount":59.970000000000006}`,
+			want: Order{
+				ID:       "o3",
+				Items:    []OrderItem{{ProductName: "Mouse", Quantity: 1, UnitPrice: 5997}},
+				Amount:   5997,
+				Currency: DefaultCurrency,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Order
+			if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.want.ID || got.Amount != tt.want.Amount || got.Currency != tt.want.Currency ||
+				got.Status != tt.want.Status || len(got.Items) != len(tt.want.Items) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got.Items {
+				if got.Items[i] != tt.want.Items[i] {
+					t.Errorf("item %d = %+v, want %+v", i, got.Items[i], tt.want.Items[i])
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,116 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// OrderStatus is a step in the order lifecycle:
+//
+//	pending ──▶ confirmed ──▶ refunded
+//	   │            │
+//	   └────────────┴──▶ cancelled
+//
+// cancelled and refunded are terminal.
+type OrderStatus string
+
+const (
+	StatusPending   OrderStatus = "pending"
+	StatusConfirmed OrderStatus = "confirmed"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusRefunded  OrderStatus = "refunded"
+)
+
+// transitions lists, for each status, the statuses it may move to.
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusCancelled, StatusRefunded},
+}
+
+// Valid reports whether s is one of the known statuses.
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusConfirmed, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+// IsSale reports whether an order in status s counts towards sales on the
+// dashboards. Cancelled and refunded orders do not.
+func (s OrderStatus) IsSale() bool {
+	return s != StatusCancelled && s != StatusRefunded
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, t := range transitions[s] {
+		if t == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is a cart of one or more line items, all priced in Currency.
+// Amount is the order total, computed from Items by Total when the order
+// is created.
 type Order struct {
-	ID          string    `json:"id"`
-	ProductName string    `json:"product_name"`
-	Amount      float64   `json:"amount"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID        string      `json:"id"`
+	Items     []OrderItem `json:"items"`
+	Amount    Money       `json:"amount"`
+	Currency  string      `json:"currency"`
+	Status    OrderStatus `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderItem is one line of an order.
+type OrderItem struct {
+	SKU         string `json:"sku"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   Money  `json:"unit_price"`
+}
+
+// Total sums the line totals exactly.
+func (o *Order) Total() Money {
+	var total Money
+	for _, item := range o.Items {
+		total += item.UnitPrice.Mul(item.Quantity)
+	}
+	return total
+}
+
+// UnmarshalJSON also accepts the single-product shape orders had before line
+// items, {"product_name": ..., "amount": ...}, as one line of quantity 1, and
+// defaults a missing currency to DefaultCurrency. Queued messages, outbox
+// rows and cache entries written by older releases still use these shapes,
+// and their float totals are rounded to cents (see Money.UnmarshalJSON).
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plain Order // drops this method, so the call below does not recurse
+	var aux struct {
+		plain
+		ProductName string `json:"product_name"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	*o = Order(aux.plain)
+	if len(o.Items) == 0 && aux.ProductName != "" {
+		o.Items = []OrderItem{{ProductName: aux.ProductName, Quantity: 1, UnitPrice: o.Amount}}
+	}
+	if o.Currency == "" {
+		o.Currency = DefaultCurrency
+	}
+	return nil
+}
+
+// StatusChange is the queue message asking the worker to move an order to a
+// new status. RequestedAt becomes the order's updated_at.
+type StatusChange struct {
+	OrderID     string      `json:"order_id"`
+	Status      OrderStatus `json:"status"`
+	RequestedAt time.Time   `json:"requested_at"`
 }
@@ -1,6 +1,8 @@
 // Package queue wraps RabbitMQ for reliable, decoupled message passing.
 //
-// The API service publishes Order events to the "order_queue".
+// The API service publishes Order events to the "order_queue". The AMQP type
+// property says which event a message carries: MessageOrderCreated (the body is
+// a models.Order) or MessageStatusChanged (the body is a models.StatusChange).
 // The worker service consumes from the same queue and persists data to Postgres + Elasticsearch.
 //
 // Durability guarantees:
@@ -8,133 +10,386 @@
 //   - Messages are marked as Persistent — written to disk before ack.
 //   - Consumer uses manual ack — a message is only removed from the queue
 //     after the worker has successfully written to both Postgres and ES.
+//
+// Retry topology:
+//   - A failed message is rejected without requeue, which dead-letters it via
+//     "order_queue.dlx" into "order_queue.retry".
+//   - The retry queue has a message TTL and dead-letters back into "order_queue",
+//     so every retry waits retryDelay instead of spinning at full speed.
+//   - RabbitMQ records each rejection in the x-death header. Once a message has
+//     been attempted maxAttempts times it is parked in "order_queue.dlq" for
+//     manual inspection instead of blocking the queue forever.
 package queue
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
+	"go-polyglot-persistence/internal/metrics"
 	"go-polyglot-persistence/internal/models"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-const orderQueueName = "order_queue"
+const (
+	orderQueueName      = "order_queue"
+	retryQueueName      = orderQueueName + ".retry"
+	deadLetterQueueName = orderQueueName + ".dlq"
+	deadLetterExchange  = orderQueueName + ".dlx"
+
+	// retryDelay is the TTL of the retry queue. It is part of the queue
+	// declaration, so it must be identical for every service that declares it.
+	retryDelay = 5 * time.Second
+
+	// deadLetterTimeout caps the publish to the DLQ when a message is parked.
+	deadLetterTimeout = 5 * time.Second
+
+	// publishTimeout caps how long PublishOrder waits for a reconnect and the
+	// broker's confirm. It is shorter than the API's WriteTimeout so the
+	// handler can still return a clean 500.
+	publishTimeout = 5 * time.Second
+
+	// returnBuffer is the capacity of the mandatory-return channel. Publishes
+	// are serialised and each drains the channel, so at most the return of a
+	// timed-out publish and that of the current one are ever buffered; the
+	// slack keeps amqp091's connection reader from blocking on a full channel.
+	returnBuffer = 8
+)
+
+// Message types carried in the AMQP type property.
+// Messages without a type predate status changes and are order creations.
+const (
+	MessageOrderCreated  = "order.created"
+	MessageStatusChanged = "order.status_changed"
+)
+
+// ErrNacked is returned by PublishOrder when the broker refuses a message.
+var ErrNacked = errors.New("queue: publish nacked by broker")
+
+// ErrUnroutable is returned by PublishOrder when the broker returns a
+// mandatory message because no queue is bound for its routing key.
+var ErrUnroutable = errors.New("queue: publish returned unroutable")
 
 // Publisher owns the AMQP connection for the API service side (publish only).
+//
+// The channel is in confirm mode: PublishOrder only returns nil once the broker
+// has taken responsibility for the message. Publishes are serialised so a
+// mandatory return can be attributed to the publish that caused it.
 type Publisher struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	queue   amqp.Queue
+	session *session
+
+	mu        sync.Mutex
+	confirmed *amqp.Channel    // channel on which confirm mode + returns are set up
+	returns   chan amqp.Return // mandatory returns for the confirmed channel
 }
 
 // NewPublisher dials RabbitMQ and declares the shared queue.
+// The connection is re-established automatically if the broker drops it.
 func NewPublisher(url string) (*Publisher, error) {
-	conn, err := amqp.Dial(url)
+	s, err := newSession(url, "publisher", func(ch *amqp.Channel) error {
+		_, err := declareQueue(ch)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("queue: dial: %w", err)
+		return nil, err
 	}
+	return &Publisher{session: s}, nil
+}
 
-	ch, err := conn.Channel()
+// PublishOrder serialises the order and sends it to the queue.
+// The message is marked Persistent so it survives a broker restart, and the
+// call waits for the broker's confirm. While the broker is unreachable it
+// waits for a reconnect for up to publishTimeout.
+func (p *Publisher) PublishOrder(ctx context.Context, order *models.Order) error {
+	body, err := json.Marshal(order)
 	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("queue: open channel: %w", err)
+		return err
 	}
+	return p.publish(ctx, MessageOrderCreated, order.ID, body)
+}
 
-	q, err := declareQueue(ch)
+// PublishStatusChange sends a status transition for the worker to apply,
+// with the same persistence and confirm guarantees as PublishOrder.
+func (p *Publisher) PublishStatusChange(ctx context.Context, change *models.StatusChange) error {
+	body, err := json.Marshal(change)
 	if err != nil {
-		ch.Close()
-		conn.Close()
-		return nil, err
+		return err
 	}
-
-	return &Publisher{conn: conn, channel: ch, queue: q}, nil
+	return p.publish(ctx, MessageStatusChanged, change.OrderID, body)
 }
 
-// PublishOrder serialises the order and sends it to the queue.
-// The message is marked Persistent so it survives a broker restart.
-func (p *Publisher) PublishOrder(ctx context.Context, order *models.Order) error {
-	body, err := json.Marshal(order)
+// publish sends one confirmed, mandatory, persistent message to order_queue.
+func (p *Publisher) publish(ctx context.Context, msgType, orderID string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ch, err := p.session.Channel(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("queue: publish: %w", err)
+	}
+
+	// Confirm mode and the return listener are per channel, so they are set up
+	// lazily the first time a (re)connected channel is used.
+	if ch != p.confirmed {
+		if err := ch.Confirm(false); err != nil {
+			return fmt.Errorf("queue: enable confirms: %w", err)
+		}
+		p.returns = ch.NotifyReturn(make(chan amqp.Return, returnBuffer))
+		p.confirmed = ch
 	}
 
-	return p.channel.PublishWithContext(ctx,
-		"",           // default exchange — routes directly to named queue
-		p.queue.Name, // routing key == queue name for default exchange
-		false,        // mandatory
-		false,        // immediate
+	// A return for a publish whose confirm timed out arrives late; drop it
+	// here so it is not blamed on this message.
+	p.takeReturn("")
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+		"",             // default exchange — routes directly to named queue
+		orderQueueName, // routing key == queue name for default exchange
+		true,           // mandatory — unroutable messages are returned, not dropped
+		false,          // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
 			DeliveryMode: amqp.Persistent, // survive broker restart
+			Type:         msgType,
+			MessageId:    orderID,
 			Body:         body,
 		},
 	)
+	if err != nil {
+		return fmt.Errorf("queue: publish: %w", err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("queue: await confirm: %w", err)
+	}
+
+	// The broker sends basic.return before the basic.ack for the same message,
+	// so by the time the confirm arrives any return is already buffered.
+	if ret, ok := p.takeReturn(orderID); ok {
+		return fmt.Errorf("%w: %s (%d %s)", ErrUnroutable, ret.MessageId, ret.ReplyCode, ret.ReplyText)
+	}
+
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+// takeReturn empties the buffered returns and reports the one for the
+// message with messageID, if any. Every other return belongs to an earlier
+// publish that timed out, and is logged and dropped. Callers hold p.mu.
+func (p *Publisher) takeReturn(messageID string) (amqp.Return, bool) {
+	var match amqp.Return
+	found := false
+	for {
+		select {
+		case ret, ok := <-p.returns:
+			if !ok {
+				return match, found // the channel closed; the next publish reopens it
+			}
+			if messageID != "" && ret.MessageId == messageID {
+				match, found = ret, true
+				continue
+			}
+			slog.Warn("dropping return for an earlier publish",
+				"component", "queue",
+				"message_id", ret.MessageId,
+				"reply_code", ret.ReplyCode,
+				"reply_text", ret.ReplyText,
+			)
+		default:
+			return match, found
+		}
+	}
 }
 
-// Close releases the AMQP channel and connection.
+// Close stops reconnecting and releases the AMQP channel and connection.
 func (p *Publisher) Close() {
-	p.channel.Close()
-	p.conn.Close()
+	p.session.Close()
 }
 
 // Consumer owns the AMQP connection for the worker side (consume only).
 type Consumer struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	queue   amqp.Queue
+	session     *session
+	maxAttempts int
 }
 
-// NewConsumer dials RabbitMQ and sets QoS to process one message at a time.
-func NewConsumer(url string) (*Consumer, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("queue: dial: %w", err)
-	}
+// NewConsumer dials RabbitMQ and sets QoS so at most prefetch messages are
+// unacked at a time. maxAttempts is the number of deliveries a message gets
+// before a Nack parks it in the dead-letter queue. Values below 1 are treated as 1.
+// The connection is re-established automatically if the broker drops it.
+func NewConsumer(url string, prefetch, maxAttempts int) (*Consumer, error) {
+	prefetch = max(prefetch, 1)
+	maxAttempts = max(maxAttempts, 1)
 
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("queue: open channel: %w", err)
-	}
-
-	// Process one message at a time — prevents one slow consumer from hoarding.
-	if err := ch.Qos(1, 0, false); err != nil {
-		ch.Close()
-		conn.Close()
-		return nil, fmt.Errorf("queue: set qos: %w", err)
-	}
-
-	q, err := declareQueue(ch)
+	s, err := newSession(url, "consumer", func(ch *amqp.Channel) error {
+		// Bound unacked messages — prevents one slow consumer from hoarding.
+		if err := ch.Qos(prefetch, 0, false); err != nil {
+			return fmt.Errorf("queue: set qos: %w", err)
+		}
+		// Confirms make the DLQ copy durable before the original is acked.
+		if err := ch.Confirm(false); err != nil {
+			return fmt.Errorf("queue: enable confirms: %w", err)
+		}
+		_, err := declareQueue(ch)
+		return err
+	})
 	if err != nil {
-		ch.Close()
-		conn.Close()
 		return nil, err
 	}
 
-	return &Consumer{conn: conn, channel: ch, queue: q}, nil
+	return &Consumer{session: s, maxAttempts: maxAttempts}, nil
 }
 
-// Delivery wraps amqp.Delivery to expose the decoded Order and ack/nack helpers.
+// Delivery wraps amqp.Delivery to expose the decoded message and ack/nack helpers.
+// Exactly one of Order and StatusChange is set, depending on the message type.
 type Delivery struct {
-	Order *models.Order
-	raw   amqp.Delivery
+	Order        *models.Order
+	StatusChange *models.StatusChange
+	raw          amqp.Delivery
+	channel      *amqp.Channel // channel the message arrived on; acks must go back on it
+	consumer     *Consumer
+}
+
+// OrderID returns the ID of the order the message is about.
+func (d *Delivery) OrderID() string {
+	if d.StatusChange != nil {
+		return d.StatusChange.OrderID
+	}
+	return d.Order.ID
 }
 
 // Ack removes the message from RabbitMQ after successful processing.
+// It acks this delivery tag only (multiple=false), so goroutines processing
+// deliveries in parallel can ack in any order without acking each other's work.
 func (d *Delivery) Ack() error { return d.raw.Ack(false) }
 
-// Nack requeues the message so another worker can retry.
-func (d *Delivery) Nack() error { return d.raw.Nack(false, true) }
+// Attempt returns the 1-based delivery attempt of this message, derived from
+// the number of times RabbitMQ has dead-lettered it out of order_queue.
+func (d *Delivery) Attempt() int { return rejectCount(d.raw.Headers) + 1 }
 
-// Discard permanently rejects a message (e.g. unparseable payload).
-func (d *Delivery) Discard() error { return d.raw.Nack(false, false) }
+// Nack schedules the message for another attempt via the retry queue.
+// On the final attempt the message is parked in the dead-letter queue instead.
+func (d *Delivery) Nack() error {
+	if d.Attempt() >= d.consumer.maxAttempts {
+		return deadLetter(d.channel, d.raw, "max attempts exceeded")
+	}
+	return d.raw.Nack(false, false)
+}
+
+// Discard permanently rejects a message (e.g. a payload that can never succeed)
+// by parking it in the dead-letter queue without further retries.
+func (d *Delivery) Discard() error { return deadLetter(d.channel, d.raw, "discarded") }
 
 // Consume returns a channel of Delivery values. Each value must be Ack'd or Nack'd.
+//
+// The returned channel survives reconnects: when the broker connection drops,
+// consumption resumes on the new channel once it is re-established. Unacked
+// messages from the lost channel are redelivered by RabbitMQ, and acking them
+// on the dead channel simply returns an error. The channel is closed after Close,
+// and a message not yet handed to the reader by then is requeued.
 func (c *Consumer) Consume() (<-chan Delivery, error) {
-	rawMsgs, err := c.channel.Consume(
-		c.queue.Name,
+	ch, rawMsgs, err := c.consume()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			for d := range rawMsgs {
+				delivery, err := decode(d)
+				if err != nil {
+					// Park unparseable messages — they will never be valid.
+					deadLetter(ch, d, "unparseable payload")
+					continue
+				}
+				delivery.channel = ch
+				delivery.consumer = c
+				select {
+				case out <- delivery:
+				case <-c.session.Done():
+					// Nobody is reading any more. Hand the message back rather
+					// than hold it; closing the channel would requeue it too,
+					// but only once the close gets that far.
+					d.Nack(false, true)
+					return
+				}
+			}
+
+			// The delivery stream ended: either Close was called or the channel
+			// died. In the latter case wait for the session to reconnect.
+			for {
+				ch, rawMsgs, err = c.consume()
+				if err == nil {
+					slog.Info("consumption resumed", "component", "queue")
+					break
+				}
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				slog.Error("resume consume failed", "component", "queue", "error", err)
+				select {
+				case <-c.session.Done():
+					return
+				case <-time.After(minReconnectDelay):
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// decode unmarshals the body according to the message type.
+func decode(d amqp.Delivery) (Delivery, error) {
+	switch d.Type {
+	case "", MessageOrderCreated:
+		var order models.Order
+		if err := json.Unmarshal(d.Body, &order); err != nil {
+			return Delivery{}, err
+		}
+		// Orders published before the status lifecycle existed carry neither field.
+		if order.Status == "" {
+			order.Status = models.StatusPending
+		}
+		if order.UpdatedAt.IsZero() {
+			order.UpdatedAt = order.CreatedAt
+		}
+		return Delivery{Order: &order, raw: d}, nil
+
+	case MessageStatusChanged:
+		var change models.StatusChange
+		if err := json.Unmarshal(d.Body, &change); err != nil {
+			return Delivery{}, err
+		}
+		return Delivery{StatusChange: &change, raw: d}, nil
+
+	default:
+		return Delivery{}, fmt.Errorf("queue: unknown message type %q", d.Type)
+	}
+}
+
+// consume starts a basic.consume on the session's current channel,
+// blocking until the session is connected.
+func (c *Consumer) consume() (*amqp.Channel, <-chan amqp.Delivery, error) {
+	ch, err := c.session.Channel(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawMsgs, err := ch.Consume(
+		orderQueueName,
 		"",    // consumer tag — auto-generated
 		false, // auto-ack disabled — we ack manually after successful processing
 		false, // exclusive
@@ -143,45 +398,159 @@ func (c *Consumer) Consume() (<-chan Delivery, error) {
 		nil,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("queue: consume: %w", err)
+		return nil, nil, fmt.Errorf("queue: consume: %w", err)
 	}
+	return ch, rawMsgs, nil
+}
 
-	out := make(chan Delivery)
-	go func() {
-		defer close(out)
-		for d := range rawMsgs {
-			var order models.Order
-			if err := json.Unmarshal(d.Body, &order); err != nil {
-				// Discard unparseable messages — they will never be valid.
-				d.Nack(false, false)
-				continue
-			}
-			out <- Delivery{Order: &order, raw: d}
+// deadLetter copies the message into the DLQ and then acks the original.
+// If the copy cannot be published or is not confirmed, the message is rejected
+// into the retry queue instead, so it is delayed rather than lost.
+func deadLetter(ch *amqp.Channel, d amqp.Delivery, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+		deadLetterExchange,
+		deadLetterQueueName,
+		false, // mandatory
+		false, // immediate
+		parkedPublishing(d, reason),
+	)
+	if err == nil {
+		var acked bool
+		if acked, err = confirm.WaitContext(ctx); err == nil && !acked {
+			err = ErrNacked
 		}
-	}()
+	}
+	if err != nil {
+		slog.Error("dead-letter publish failed", "component", "queue", "error", err)
+		return d.Nack(false, false)
+	}
 
-	return out, nil
+	metrics.MessagesDeadLettered.WithLabelValues(reason).Inc()
+	slog.Warn("message dead-lettered",
+		"component", "queue",
+		"queue", deadLetterQueueName,
+		"reason", reason,
+		"attempts", rejectCount(d.Headers)+1,
+	)
+	return d.Ack(false)
+}
+
+// parkedPublishing copies every property of d, so a message replayed from the
+// DLQ is the message that was parked: its type in particular decides how the
+// worker decodes it. The headers gain x-dead-letter-reason. Expiration is
+// dropped so a parked message never expires out of the DLQ, and UserId
+// because the broker rejects one that is not the consumer's own user.
+func parkedPublishing(d amqp.Delivery, reason string) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers["x-dead-letter-reason"] = reason
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
 }
 
-// Close releases the AMQP channel and connection.
+// Close stops reconnecting and releases the AMQP channel and connection.
+// The channel returned by Consume is closed once its pump goroutine notices.
 func (c *Consumer) Close() {
-	c.channel.Close()
-	c.conn.Close()
+	c.session.Close()
 }
 
 // declareQueue is shared between Publisher and Consumer to ensure both sides
-// always declare the same durable queue (idempotent — safe to call multiple times).
+// always declare the same topology (idempotent — safe to call multiple times):
+//
+//	order_queue --reject--> order_queue.dlx --> order_queue.retry --TTL--> order_queue
+//	                                        \-> order_queue.dlq   (parked by the consumer)
+//
+// RabbitMQ refuses to redeclare an existing queue with different arguments,
+// so a broker that still has the original argument-less order_queue must have
+// it deleted once before upgrading (see docs/ops.md).
 func declareQueue(ch *amqp.Channel) (amqp.Queue, error) {
+	if err := ch.ExchangeDeclare(
+		deadLetterExchange,
+		"direct",
+		true,  // durable
+		false, // auto-delete
+		false, // internal
+		false, // no-wait
+		nil,
+	); err != nil {
+		return amqp.Queue{}, fmt.Errorf("queue: declare dlx: %w", err)
+	}
+
+	if _, err := ch.QueueDeclare(
+		retryQueueName,
+		true,  // durable
+		false, // auto-delete
+		false, // exclusive
+		false, // no-wait
+		amqp.Table{
+			"x-message-ttl":             retryDelay.Milliseconds(),
+			"x-dead-letter-exchange":    "", // default exchange — routes by queue name
+			"x-dead-letter-routing-key": orderQueueName,
+		},
+	); err != nil {
+		return amqp.Queue{}, fmt.Errorf("queue: declare retry: %w", err)
+	}
+	if err := ch.QueueBind(retryQueueName, retryQueueName, deadLetterExchange, false, nil); err != nil {
+		return amqp.Queue{}, fmt.Errorf("queue: bind retry: %w", err)
+	}
+
+	if _, err := ch.QueueDeclare(deadLetterQueueName, true, false, false, false, nil); err != nil {
+		return amqp.Queue{}, fmt.Errorf("queue: declare dlq: %w", err)
+	}
+	if err := ch.QueueBind(deadLetterQueueName, deadLetterQueueName, deadLetterExchange, false, nil); err != nil {
+		return amqp.Queue{}, fmt.Errorf("queue: bind dlq: %w", err)
+	}
+
 	q, err := ch.QueueDeclare(
 		orderQueueName,
 		true,  // durable — survives broker restart
 		false, // auto-delete
 		false, // exclusive
 		false, // no-wait
-		nil,
+		amqp.Table{
+			"x-dead-letter-exchange":    deadLetterExchange,
+			"x-dead-letter-routing-key": retryQueueName,
+		},
 	)
 	if err != nil {
 		return amqp.Queue{}, fmt.Errorf("queue: declare: %w", err)
 	}
 	return q, nil
 }
+
+// rejectCount reads the x-death header RabbitMQ maintains on dead-lettered
+// messages and returns how many times the message was rejected from order_queue.
+func rejectCount(headers amqp.Table) int {
+	deaths, ok := headers["x-death"].([]any)
+	if !ok {
+		return 0
+	}
+	for _, entry := range deaths {
+		death, ok := entry.(amqp.Table)
+		if !ok || death["queue"] != orderQueueName || death["reason"] != "rejected" {
+			continue
+		}
+		if count, ok := death["count"].(int64); ok {
+			return int(count)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRejectCount(t *testing.T) {
+	death := func(queue, reason string, count any) amqp.Table {
+		return amqp.Table{"queue": queue, "reason": reason, "count": count}
+	}
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{name: "no headers", headers: nil, want: 0},
+		{name: "no x-death", headers: amqp.Table{"other": "x"}, want: 0},
+		{name: "x-death wrong type", headers: amqp.Table{"x-death": "3"}, want: 0},
+		{name: "first rejection", headers: amqp.Table{"x-death": []any{death(orderQueueName, "rejected", int64(1))}}, want: 1},
+		{
+			name: "retry queue expiry ignored",
+			headers: amqp.Table{"x-death": []any{
+				death(retryQueueName, "expired", int64(3)),
+				death(orderQueueName, "rejected", int64(3)),
+			}},
+			want: 3,
+		},
+		{name: "other queue", headers: amqp.Table{"x-death": []any{death("elsewhere", "rejected", int64(4))}}, want: 0},
+		{name: "count wrong type", headers: amqp.Table{"x-death": []any{death(orderQueueName, "rejected", int32(2))}}, want: 0},
+		{name: "entry wrong type", headers: amqp.Table{"x-death": []any{"rejected"}}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := rejectCount(tt.headers); got != tt.want {
+			t.Errorf("%s: rejectCount = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParkedPublishing(t *testing.T) {
+	d := amqp.Delivery{
+		Headers:         amqp.Table{"x-death": []any{}, "trace": "abc"},
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Priority:        3,
+		CorrelationId:   "corr",
+		ReplyTo:         "reply",
+		Expiration:      "60000",
+		MessageId:       "msg-1",
+		Timestamp:       time.Date(2025, 3, 12, 10, 0, 0, 0, time.UTC),
+		Type:            "order.created",
+		UserId:          "guest",
+		AppId:           "api",
+		Body:            []byte(`{"id":"o1"}`),
+	}
+
+	p := parkedPublishing(d, "max retries exceeded")
+
+	if _, ok := d.Headers["x-dead-letter-reason"]; ok {
+		t.Error("parkedPublishing modified the delivery's headers")
+	}
+	if p.Headers["x-dead-letter-reason"] != "max retries exceeded" || p.Headers["trace"] != "abc" {
+		t.Errorf("headers = %v", p.Headers)
+	}
+	if p.ContentType != d.ContentType || p.ContentEncoding != d.ContentEncoding ||
+		p.Priority != d.Priority || p.CorrelationId != d.CorrelationId || p.ReplyTo != d.ReplyTo ||
+		p.MessageId != d.MessageId || !p.Timestamp.Equal(d.Timestamp) || p.Type != d.Type ||
+		p.AppId != d.AppId || string(p.Body) != string(d.Body) {
+		t.Errorf("properties not copied: %+v", p)
+	}
+	if p.DeliveryMode != amqp.Persistent {
+		t.Errorf("DeliveryMode = %d, want persistent", p.DeliveryMode)
+	}
+	if p.Expiration != "" || p.UserId != "" {
+		t.Errorf("Expiration = %q, UserId = %q; want both dropped", p.Expiration, p.UserId)
+	}
+}
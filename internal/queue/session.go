@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-polyglot-persistence/internal/metrics"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Reconnect backoff bounds. The delay doubles after every failed dial,
+// so a broker restart is picked up within a second while a long outage
+// does not hammer the broker with connection attempts.
+const (
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ErrClosed is returned by operations on a Publisher or Consumer after Close.
+var ErrClosed = errors.New("queue: closed")
+
+// session owns one AMQP connection and channel and transparently re-establishes
+// both whenever the broker closes them (restart, network partition, channel error).
+//
+// setup runs on every fresh channel before it is handed out, so topology
+// declarations and QoS are re-applied after a reconnect.
+type session struct {
+	url   string
+	name  string // "publisher" or "consumer" — used in logs and metrics
+	setup func(ch *amqp.Channel) error
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	ready   chan struct{} // closed while channel is usable; replaced when it is lost
+
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// newSession dials synchronously so main() still fails fast on a bad URL or a
+// broker that is down at startup, then watches the connection in the background.
+func newSession(url, name string, setup func(ch *amqp.Channel) error) (*session, error) {
+	s := &session{
+		url:   url,
+		name:  name,
+		setup: setup,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	go s.watch()
+	return s, nil
+}
+
+// connect dials, opens a channel, runs setup, and publishes the result.
+func (s *session) connect() error {
+	conn, err := amqp.Dial(s.url)
+	if err != nil {
+		return fmt.Errorf("queue: dial: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("queue: open channel: %w", err)
+	}
+
+	if err := s.setup(ch); err != nil {
+		ch.Close()
+		conn.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.conn = conn
+	s.channel = ch
+	close(s.ready)
+	s.mu.Unlock()
+	return nil
+}
+
+// watch blocks until the current connection or channel dies, then redials
+// with exponential backoff. It returns once the session is closed.
+func (s *session) watch() {
+	for {
+		s.mu.Lock()
+		connClosed := s.conn.NotifyClose(make(chan *amqp.Error, 1))
+		chanClosed := s.channel.NotifyClose(make(chan *amqp.Error, 1))
+		s.mu.Unlock()
+
+		var reason *amqp.Error
+		select {
+		case <-s.done:
+			return
+		case reason = <-connClosed:
+		case reason = <-chanClosed:
+		}
+
+		s.mu.Lock()
+		s.ready = make(chan struct{})
+		s.channel.Close()
+		s.conn.Close()
+		s.mu.Unlock()
+
+		slog.Warn("rabbitmq connection lost", "component", "queue", "side", s.name, "reason", reason)
+
+		delay := minReconnectDelay
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-time.After(delay):
+			}
+
+			err := s.connect()
+			if err == nil {
+				break
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			slog.Error("rabbitmq reconnect failed",
+				"component", "queue",
+				"side", s.name,
+				"retry_in", delay,
+				"error", err,
+			)
+		}
+
+		metrics.QueueReconnects.WithLabelValues(s.name).Inc()
+		slog.Info("rabbitmq reconnected", "component", "queue", "side", s.name)
+	}
+}
+
+// Channel returns the current channel, waiting for a reconnect if necessary.
+// It fails with ctx.Err() if the broker is not back in time, or ErrClosed
+// once the session has been closed.
+func (s *session) Channel(ctx context.Context) (*amqp.Channel, error) {
+	select {
+	case <-s.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	s.mu.Lock()
+	ready := s.ready
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-s.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channel, nil
+}
+
+// Done is closed once Close has been called.
+func (s *session) Done() <-chan struct{} { return s.done }
+
+// Close stops reconnecting and releases the channel and connection.
+func (s *session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.channel.Close()
+		s.conn.Close()
+	})
+}
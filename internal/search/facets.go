@@ -0,0 +1,185 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go-polyglot-persistence/internal/models"
+)
+
+// Facet names a breakdown of the matching orders that SearchOrders can
+// compute with ES aggregations alongside the hits. Facets always cover
+// every match, not just the current page.
+type Facet string
+
+const (
+	FacetProducts Facet = "products" // orders per product name, top productFacetSize
+	FacetAmount   Facet = "amount"   // orders per amount bucket, per currency
+	FacetCreated  Facet = "created"  // orders per calendar day, week or month (UTC)
+)
+
+// Valid reports whether f is a facet SearchOrders computes.
+func (f Facet) Valid() bool {
+	switch f {
+	case FacetProducts, FacetAmount, FacetCreated:
+		return true
+	}
+	return false
+}
+
+// productFacetSize is how many products the products facet lists.
+const productFacetSize = 10
+
+// Facets holds the requested breakdowns; the others are nil and left out of
+// the JSON. Buckets with no orders are left out too. Weeks start on Monday.
+type Facets struct {
+	Products []ProductFacet `json:"products,omitzero"`
+	Amount   []AmountFacet  `json:"amount,omitzero"`
+	Created  []CreatedFacet `json:"created,omitzero"`
+}
+
+// ProductFacet counts the matching orders with at least one item of Product.
+// The list is ordered by Orders, most first.
+type ProductFacet struct {
+	Product string `json:"product"`
+	Orders  int    `json:"orders"`
+}
+
+// AmountFacet counts the matching orders in Currency whose total is at
+// least From and below To. Totals in different currencies are never
+// bucketed together.
+type AmountFacet struct {
+	Currency string       `json:"currency"`
+	From     models.Money `json:"from"`
+	To       models.Money `json:"to"`
+	Orders   int          `json:"orders"`
+}
+
+// CreatedFacet counts the matching orders created in the UTC day, week or
+// month starting on Date (YYYY-MM-DD).
+type CreatedFacet struct {
+	Date   string `json:"date"`
+	Orders int    `json:"orders"`
+}
+
+// aggregations builds the ES aggregations for the facets q asks for.
+func (q OrderQuery) aggregations() map[string]any {
+	aggs := map[string]any{}
+	for _, f := range q.Facets {
+		switch f {
+		case FacetProducts:
+			// Terms over nested items count items; reverse_nested counts the
+			// orders they belong to, and ranks the products by that.
+			aggs[string(f)] = map[string]any{
+				"nested": map[string]any{"path": "items"},
+				"aggs": map[string]any{
+					"names": map[string]any{
+						"terms": map[string]any{
+							"field": "items.product_name.keyword",
+							"size":  productFacetSize,
+							"order": map[string]any{"orders": "desc"},
+						},
+						"aggs": map[string]any{
+							"orders": map[string]any{"reverse_nested": map[string]any{}},
+						},
+					},
+				},
+			}
+		case FacetAmount:
+			aggs[string(f)] = map[string]any{
+				"terms": map[string]any{"field": "currency", "size": 100},
+				"aggs": map[string]any{
+					"histogram": map[string]any{
+						"histogram": map[string]any{
+							"field":         "amount",
+							"interval":      q.AmountInterval,
+							"min_doc_count": 1,
+						},
+					},
+				},
+			}
+		case FacetCreated:
+			aggs[string(f)] = map[string]any{
+				"date_histogram": map[string]any{
+					"field":             "created_at",
+					"calendar_interval": q.CreatedInterval,
+					"format":            "yyyy-MM-dd",
+					"min_doc_count":     1,
+				},
+			}
+		}
+	}
+	return aggs
+}
+
+// parseFacets reads the aggregations built by aggregations.
+func (q OrderQuery) parseFacets(raw map[string]json.RawMessage) (*Facets, error) {
+	facets := &Facets{}
+
+	if data, ok := raw[string(FacetProducts)]; ok {
+		var agg struct {
+			Names struct {
+				Buckets []struct {
+					Key    string `json:"key"`
+					Orders struct {
+						DocCount int `json:"doc_count"`
+					} `json:"orders"`
+				} `json:"buckets"`
+			} `json:"names"`
+		}
+		if err := json.Unmarshal(data, &agg); err != nil {
+			return nil, fmt.Errorf("search: decode products facet: %w", err)
+		}
+		facets.Products = []ProductFacet{}
+		for _, b := range agg.Names.Buckets {
+			facets.Products = append(facets.Products, ProductFacet{Product: b.Key, Orders: b.Orders.DocCount})
+		}
+	}
+
+	if data, ok := raw[string(FacetAmount)]; ok {
+		var agg struct {
+			Buckets []struct {
+				Key       string `json:"key"`
+				Histogram struct {
+					Buckets []struct {
+						Key      float64 `json:"key"`
+						DocCount int     `json:"doc_count"`
+					} `json:"buckets"`
+				} `json:"histogram"`
+			} `json:"buckets"`
+		}
+		if err := json.Unmarshal(data, &agg); err != nil {
+			return nil, fmt.Errorf("search: decode amount facet: %w", err)
+		}
+		facets.Amount = []AmountFacet{}
+		for _, currency := range agg.Buckets {
+			for _, b := range currency.Histogram.Buckets {
+				from := models.Money(math.Round(b.Key * 100)) // the key is in major units
+				facets.Amount = append(facets.Amount, AmountFacet{
+					Currency: currency.Key,
+					From:     from,
+					To:       from + q.AmountInterval,
+					Orders:   b.DocCount,
+				})
+			}
+		}
+	}
+
+	if data, ok := raw[string(FacetCreated)]; ok {
+		var agg struct {
+			Buckets []struct {
+				Key      string `json:"key_as_string"`
+				DocCount int    `json:"doc_count"`
+			} `json:"buckets"`
+		}
+		if err := json.Unmarshal(data, &agg); err != nil {
+			return nil, fmt.Errorf("search: decode created facet: %w", err)
+		}
+		facets.Created = []CreatedFacet{}
+		for _, b := range agg.Buckets {
+			facets.Created = append(facets.Created, CreatedFacet{Date: b.Key, Orders: b.DocCount})
+		}
+	}
+	return facets, nil
+}
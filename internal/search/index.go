@@ -0,0 +1,387 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Orders live in versioned indices (orders_v1, orders_v2, ...). Nothing
+// names a version directly: searches go through the read alias and the
+// worker writes through the write alias, so a reindex can build the next
+// version beside the live one and move both aliases in one atomic request.
+const (
+	ordersReadAlias   = "orders_read"
+	ordersWriteAlias  = "orders_write"
+	ordersIndexPrefix = "orders_v"
+
+	// legacyOrdersIndex is the unversioned index used before aliases. It
+	// lacks the current mapping, so EnsureIndex refuses to start on it and
+	// the first reindex replaces it with orders_v1.
+	legacyOrdersIndex = "orders"
+)
+
+// ErrReindexRequired is returned by EnsureIndex when only the legacy
+// unversioned index exists. Run the reindex command to build orders_v1.
+var ErrReindexRequired = errors.New("search: orders index is unversioned; run the reindex command")
+
+// maxPrefix is the longest word prefix product_prefix indexes.
+const maxPrefix = 20
+
+// ordersSettings are the settings every orders_v* index is created with.
+// product_text folds case and accents, so "cafe" finds "Café".
+// product_prefix also indexes every word's leading 1-20 characters, so
+// "lap" finds "Laptop" for autocomplete. Queries against it use
+// product_prefix_query, which is product_text with each word cut to 20
+// characters: without the cut "lap" would match anything starting with
+// "l", and with no cut at all a longer word would match nothing.
+// product_folded is the same folding for keywords, so names differing only
+// in case or accents count as one product.
+var ordersSettings = map[string]any{
+	"number_of_shards": 1,
+	"refresh_interval": "1s",
+	"analysis": map[string]any{
+		"filter": map[string]any{
+			"product_prefix_ngram": map[string]any{
+				"type":     "edge_ngram",
+				"min_gram": 1,
+				"max_gram": maxPrefix,
+			},
+			"product_prefix_truncate": map[string]any{
+				"type":   "truncate",
+				"length": maxPrefix,
+			},
+		},
+		"analyzer": map[string]any{
+			"product_text": map[string]any{
+				"type":      "custom",
+				"tokenizer": "standard",
+				"filter":    []string{"lowercase", "asciifolding"},
+			},
+			"product_prefix": map[string]any{
+				"type":      "custom",
+				"tokenizer": "standard",
+				"filter":    []string{"lowercase", "asciifolding", "product_prefix_ngram"},
+			},
+			"product_prefix_query": map[string]any{
+				"type":      "custom",
+				"tokenizer": "standard",
+				"filter":    []string{"lowercase", "asciifolding", "product_prefix_truncate"},
+			},
+		},
+		"normalizer": map[string]any{
+			"product_folded": map[string]any{
+				"type":   "custom",
+				"filter": []string{"lowercase", "asciifolding"},
+			},
+		},
+	},
+}
+
+// itemsMapping maps line items as nested documents, so a query can require
+// that one item matches several conditions (e.g. this SKU at quantity > 1)
+// rather than matching them across different items of the same order.
+var itemsMapping = map[string]any{
+	"type": "nested",
+	"properties": map[string]any{
+		"sku": map[string]any{"type": "keyword"},
+		"product_name": map[string]any{
+			"type":     "text",
+			"analyzer": "product_text",
+			"fields": map[string]any{
+				"keyword": map[string]any{"type": "keyword", "ignore_above": 256},
+				"folded": map[string]any{
+					"type":         "keyword",
+					"normalizer":   "product_folded",
+					"ignore_above": 256,
+				},
+				"prefix": map[string]any{
+					"type":            "text",
+					"analyzer":        "product_prefix",
+					"search_analyzer": "product_prefix_query",
+				},
+			},
+		},
+		"quantity":   map[string]any{"type": "integer"},
+		"unit_price": moneyMapping,
+	},
+}
+
+// moneyMapping stores models.Money exactly: a scaled_float with a factor of
+// 100 is an integer count of cents under the hood.
+var moneyMapping = map[string]any{"type": "scaled_float", "scaling_factor": 100}
+
+// ordersMapping is the full mapping of an orders_v* index. Dynamic mapping
+// is off: a field added to models.Order is kept in _source but not
+// searchable until it is mapped here and the orders are reindexed.
+var ordersMapping = map[string]any{
+	"dynamic": false,
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"items":      itemsMapping,
+		"amount":     moneyMapping,
+		"currency":   map[string]any{"type": "keyword"},
+		"status":     map[string]any{"type": "keyword"},
+		"created_at": map[string]any{"type": "date"},
+		"updated_at": map[string]any{"type": "date"},
+	},
+}
+
+// EnsureIndex makes sure the read and write aliases exist. On a fresh
+// cluster it creates orders_v1 with both aliases in the same request. If
+// the legacy unversioned index is all there is, or the aliases still point
+// at it, it returns ErrReindexRequired: that index has none of the nested,
+// scaled_float or prefix mappings, so searches and suggestions against it
+// would quietly return wrong results.
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	current, err := c.aliasIndices(ctx, ordersWriteAlias)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(current, legacyOrdersIndex) {
+		return ErrReindexRequired
+	}
+	if len(current) > 0 {
+		return nil
+	}
+
+	legacy, err := c.indexExists(ctx, legacyOrdersIndex)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		return ErrReindexRequired
+	}
+
+	err = c.createIndex(ctx, ordersIndexPrefix+"1", ordersSettings, map[string]any{
+		ordersReadAlias:  map[string]any{},
+		ordersWriteAlias: map[string]any{"is_write_index": true},
+	})
+	if err != nil && strings.Contains(err.Error(), "resource_already_exists_exception") {
+		return nil // another replica created it first
+	}
+	return err
+}
+
+// CreateNextIndex creates the next orders_v* index, one version above the
+// highest that exists, with the current settings and mapping but no
+// aliases. Refresh is disabled for a fast backfill until FinishIndex.
+func (c *Client) CreateNextIndex(ctx context.Context) (string, error) {
+	res, err := c.es.Cat.Indices(
+		c.es.Cat.Indices.WithIndex(ordersIndexPrefix+"*"),
+		c.es.Cat.Indices.WithH("index"),
+		c.es.Cat.Indices.WithFormat("json"),
+		c.es.Cat.Indices.WithContext(ctx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("search: list indices request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return "", fmt.Errorf("search: list indices error [%s]: %s", res.Status(), body)
+	}
+
+	var indices []struct {
+		Index string `json:"index"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return "", fmt.Errorf("search: decode indices: %w", err)
+	}
+
+	latest := 0
+	for _, idx := range indices {
+		if v, err := strconv.Atoi(strings.TrimPrefix(idx.Index, ordersIndexPrefix)); err == nil && v > latest {
+			latest = v
+		}
+	}
+
+	name := ordersIndexPrefix + strconv.Itoa(latest+1)
+	settings := maps.Clone(ordersSettings)
+	settings["refresh_interval"] = "-1"
+	if err := c.createIndex(ctx, name, settings, nil); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// FinishIndex restores the refresh interval CreateNextIndex disabled and
+// refreshes index, so every backfilled document is searchable before the
+// aliases move to it.
+func (c *Client) FinishIndex(ctx context.Context, index string) error {
+	body, err := json.Marshal(map[string]any{"index": map[string]any{"refresh_interval": ordersSettings["refresh_interval"]}})
+	if err != nil {
+		return err
+	}
+	res, err := c.es.Indices.PutSettings(bytes.NewReader(body),
+		c.es.Indices.PutSettings.WithIndex(index),
+		c.es.Indices.PutSettings.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("search: put settings request: %w", err)
+	}
+	if err := checkResponse(res, "put settings"); err != nil {
+		return err
+	}
+
+	res, err = c.es.Indices.Refresh(
+		c.es.Indices.Refresh.WithIndex(index),
+		c.es.Indices.Refresh.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("search: refresh request: %w", err)
+	}
+	return checkResponse(res, "refresh")
+}
+
+// SwapAliases points the read and write aliases at index, removing them from
+// whichever indices held them, in a single _aliases request: searches and
+// writes see either the old index or the new one, never neither. It returns
+// the indices that held the aliases before, or the legacy unversioned index
+// if there were no aliases yet, so that -delete-old removes it too.
+func (c *Client) SwapAliases(ctx context.Context, index string) ([]string, error) {
+	var previous []string
+	var actions []map[string]any
+	for _, alias := range []string{ordersReadAlias, ordersWriteAlias} {
+		current, err := c.aliasIndices(ctx, alias)
+		if err != nil {
+			return nil, err
+		}
+		for _, old := range current {
+			if old == index {
+				continue
+			}
+			actions = append(actions, map[string]any{"remove": map[string]any{"index": old, "alias": alias}})
+			if !slices.Contains(previous, old) {
+				previous = append(previous, old)
+			}
+		}
+	}
+	if len(previous) == 0 {
+		legacy, err := c.indexExists(ctx, legacyOrdersIndex)
+		if err != nil {
+			return nil, err
+		}
+		if legacy {
+			previous = append(previous, legacyOrdersIndex)
+		}
+	}
+	actions = append(actions,
+		map[string]any{"add": map[string]any{"index": index, "alias": ordersReadAlias}},
+		map[string]any{"add": map[string]any{"index": index, "alias": ordersWriteAlias, "is_write_index": true}},
+	)
+
+	body, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.es.Indices.UpdateAliases(bytes.NewReader(body),
+		c.es.Indices.UpdateAliases.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search: update aliases request: %w", err)
+	}
+	if err := checkResponse(res, "update aliases"); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// DeleteIndex deletes an index, e.g. one a reindex replaced or abandoned.
+func (c *Client) DeleteIndex(ctx context.Context, index string) error {
+	res, err := c.es.Indices.Delete([]string{index}, c.es.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("search: delete index request: %w", err)
+	}
+	return checkResponse(res, "delete index")
+}
+
+// createIndex creates index with the given settings, ordersMapping and
+// optional aliases.
+func (c *Client) createIndex(ctx context.Context, index string, settings, aliases map[string]any) error {
+	spec := map[string]any{"settings": settings, "mappings": ordersMapping}
+	if aliases != nil {
+		spec["aliases"] = aliases
+	}
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	res, err := c.es.Indices.Create(index,
+		c.es.Indices.Create.WithBody(bytes.NewReader(body)),
+		c.es.Indices.Create.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("search: create index request: %w", err)
+	}
+	return checkResponse(res, "create index")
+}
+
+// aliasIndices returns the indices alias points at; none if it does not exist.
+func (c *Client) aliasIndices(ctx context.Context, alias string) ([]string, error) {
+	res, err := c.es.Indices.GetAlias(
+		c.es.Indices.GetAlias.WithName(alias),
+		c.es.Indices.GetAlias.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search: get alias request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("search: get alias error [%s]: %s", res.Status(), body)
+	}
+
+	var byIndex map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&byIndex); err != nil {
+		return nil, fmt.Errorf("search: decode aliases: %w", err)
+	}
+	indices := make([]string, 0, len(byIndex))
+	for index := range byIndex {
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+	return indices, nil
+}
+
+func (c *Client) indexExists(ctx context.Context, index string) (bool, error) {
+	res, err := c.es.Indices.Exists([]string{index}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("search: index exists request: %w", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("search: index exists error [%s]", res.Status())
+	}
+	return true, nil
+}
+
+// checkResponse closes res and turns an error status into an error naming op.
+func checkResponse(res *esapi.Response, op string) error {
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("search: %s error [%s]: %s", op, res.Status(), body)
+	}
+	return nil
+}
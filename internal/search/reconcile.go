@@ -0,0 +1,172 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// CompareOrders looks up the documents for the given orders, keyed by ID
+// with their Postgres updated_at, and reports which have no document and
+// which have one older than the order. A document newer than the order
+// is not stale: the worker writes Postgres first, so that cannot happen
+// short of restoring Postgres from a backup.
+func (c *Client) CompareOrders(ctx context.Context, updated map[string]time.Time) (missing, stale []string, err error) {
+	if len(updated) == 0 {
+		return nil, nil, nil
+	}
+
+	ids := make([]string, 0, len(updated))
+	for id := range updated {
+		ids = append(ids, id)
+	}
+	body, err := json.Marshal(map[string]any{"ids": ids})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := c.es.Mget(bytes.NewReader(body),
+		c.es.Mget.WithIndex(ordersReadAlias),
+		c.es.Mget.WithSource("false"),
+		c.es.Mget.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("search: mget request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return nil, nil, fmt.Errorf("search: mget error [%s]: %s", res.Status(), body)
+	}
+
+	var parsed struct {
+		Docs []struct {
+			ID      string          `json:"_id"`
+			Version int64           `json:"_version"`
+			Found   bool            `json:"found"`
+			Error   json.RawMessage `json:"error"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, nil, fmt.Errorf("search: decode mget response: %w", err)
+	}
+
+	for _, doc := range parsed.Docs {
+		switch {
+		case doc.Error != nil:
+			return nil, nil, fmt.Errorf("search: mget %s: %s", doc.ID, doc.Error)
+		case !doc.Found:
+			missing = append(missing, doc.ID)
+		case doc.Version < int64(documentVersion(updated[doc.ID])):
+			stale = append(stale, doc.ID)
+		}
+	}
+	return missing, stale, nil
+}
+
+// OrderIDsAfter returns up to limit document IDs in ascending order,
+// starting after the given ID ("" for the first page), so a caller can walk
+// every document in keyset pages.
+func (c *Client) OrderIDsAfter(ctx context.Context, after string, limit int) ([]string, error) {
+	query := map[string]any{
+		"size":             limit,
+		"_source":          false,
+		"sort":             []any{map[string]any{"id": "asc"}},
+		"track_total_hits": false,
+	}
+	if after != "" {
+		query["search_after"] = []string{after}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, err
+	}
+
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(ordersReadAlias),
+		c.es.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search: scan request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("search: scan error [%s]: %s", res.Status(), body)
+	}
+
+	var parsed struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("search: decode scan response: %w", err)
+	}
+
+	ids := make([]string, len(parsed.Hits.Hits))
+	for i, hit := range parsed.Hits.Hits {
+		ids[i] = hit.ID
+	}
+	return ids, nil
+}
+
+// DeleteOrders deletes the documents with the given IDs through the write
+// alias. A document that is already gone counts as deleted.
+func (c *Client) DeleteOrders(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, id := range ids {
+		if err := enc.Encode(map[string]any{"delete": map[string]any{"_index": ordersWriteAlias, "_id": id}}); err != nil {
+			return err
+		}
+	}
+
+	res, err := c.es.Bulk(&buf, c.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("search: bulk delete request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("search: bulk delete error [%s]: %s", res.Status(), body)
+	}
+
+	var parsed struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("search: decode bulk delete response: %w", err)
+	}
+	if !parsed.Errors {
+		return nil
+	}
+	for _, item := range parsed.Items {
+		for _, result := range item { // single key: "delete"
+			if result.Status >= 300 && result.Status != http.StatusNotFound {
+				return fmt.Errorf("search: bulk delete %s [%d]: %s", result.ID, result.Status, result.Error)
+			}
+		}
+	}
+	return nil
+}
@@ -8,8 +8,12 @@
 //     without expensive GROUP BY scans on the primary database.
 //
 // Index lifecycle:
-//   - The worker calls IndexOrder after every successful Postgres insert.
+//   - The API and the worker call EnsureIndex at startup so the aliases and
+//     mapping exist before the first document is written or searched.
+//   - The worker calls IndexOrders after every successful Postgres batch insert.
 //   - The API calls SearchOrders to serve the GET /api/search endpoint.
+//   - The reindex command rebuilds the index from Postgres into a new version
+//     and swaps the aliases to it, e.g. after a mapping change.
 //   - Postgres remains the source of truth; ES is a read-optimised projection.
 package search
 
@@ -19,14 +23,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"time"
 
 	"go-polyglot-persistence/internal/models"
 
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-const ordersIndex = "orders"
-
 // Client wraps the Elasticsearch client with domain-level operations.
 type Client struct {
 	es *elasticsearch.Client
@@ -44,9 +48,13 @@ func New(url string) (*Client, error) {
 	return &Client{es: es}, nil
 }
 
-// IndexOrder upserts an Order document into the "orders" index.
+// IndexOrder upserts an Order document through the write alias.
 // Using the order ID as the document ID makes this idempotent —
 // re-indexing the same order on a worker retry will not create duplicates.
+//
+// The document is versioned externally by updated_at, so a replayed, older
+// copy of an order (e.g. a redelivered creation after a status change) is
+// rejected by ES instead of overwriting newer data. That rejection is success.
 func (c *Client) IndexOrder(ctx context.Context, order *models.Order) error {
 	body, err := json.Marshal(order)
 	if err != nil {
@@ -54,9 +62,11 @@ func (c *Client) IndexOrder(ctx context.Context, order *models.Order) error {
 	}
 
 	res, err := c.es.Index(
-		ordersIndex,
+		ordersWriteAlias,
 		bytes.NewReader(body),
 		c.es.Index.WithDocumentID(order.ID),
+		c.es.Index.WithVersion(documentVersion(order.UpdatedAt)),
+		c.es.Index.WithVersionType("external_gte"),
 		c.es.Index.WithContext(ctx),
 	)
 	if err != nil {
@@ -64,6 +74,9 @@ func (c *Client) IndexOrder(ctx context.Context, order *models.Order) error {
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusConflict {
+		return nil // ES already holds a newer version
+	}
 	if res.IsError() {
 		body, _ := io.ReadAll(res.Body)
 		return fmt.Errorf("search: index error [%s]: %s", res.Status(), body)
@@ -71,27 +84,163 @@ func (c *Client) IndexOrder(ctx context.Context, order *models.Order) error {
 	return nil
 }
 
-// SearchOrders executes a full-text match query against the product_name field.
-// It returns the raw Elasticsearch response body for the API to proxy directly.
-func (c *Client) SearchOrders(ctx context.Context, term string) (json.RawMessage, error) {
-	query := map[string]any{
-		"query": map[string]any{
-			"match": map[string]any{
-				"product_name": term,
+// IndexOrders upserts a batch of Order documents through the _bulk API,
+// with the same external versioning as IndexOrder. The returned slice is
+// aligned with orders: a nil entry means that document was indexed, a
+// non-nil entry carries the per-item failure. A non-nil error
+// means the bulk request as a whole failed and no item result is known.
+func (c *Client) IndexOrders(ctx context.Context, orders []*models.Order) ([]error, error) {
+	return c.IndexOrdersInto(ctx, ordersWriteAlias, orders)
+}
+
+// IndexOrdersInto is IndexOrders against a named index rather than the write
+// alias, for a reindex backfilling an index the aliases do not point at yet.
+func (c *Client) IndexOrdersInto(ctx context.Context, index string, orders []*models.Order) ([]error, error) {
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf) // Encode appends the newline NDJSON requires
+	for _, order := range orders {
+		action := map[string]any{
+			"index": map[string]any{
+				"_index":       index,
+				"_id":          order.ID,
+				"version":      documentVersion(order.UpdatedAt),
+				"version_type": "external_gte",
 			},
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(order); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := c.es.Bulk(
+		&buf,
+		c.es.Bulk.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search: bulk request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("search: bulk error [%s]: %s", res.Status(), body)
+	}
+
+	var parsed struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("search: decode bulk response: %w", err)
+	}
+	if len(parsed.Items) != len(orders) {
+		return nil, fmt.Errorf("search: bulk response has %d items, want %d", len(parsed.Items), len(orders))
+	}
+
+	itemErrs := make([]error, len(orders))
+	if !parsed.Errors {
+		return itemErrs, nil
+	}
+	for i, item := range parsed.Items {
+		for _, result := range item { // single key: the action name, "index"
+			if result.Status >= 300 && result.Status != http.StatusConflict {
+				itemErrs[i] = fmt.Errorf("search: bulk item [%d]: %s", result.Status, result.Error)
+			}
+		}
+	}
+	return itemErrs, nil
+}
+
+// documentVersion is the external ES version of an order: its updated_at in
+// microseconds, the precision Postgres stores, so replays compare equal.
+func documentVersion(updatedAt time.Time) int {
+	return int(updatedAt.UnixMicro())
+}
+
+// OrderQuery is a search over orders. Term matches item product names
+// (full text) and SKUs (exactly), ranked by relevance; without a term every
+// order matches. The other fields filter without affecting the ranking, and
+// zero values mean "no filter". Product matches orders with at least one
+// item of exactly that name. The amount bounds are inclusive and apply to
+// the order total, which is only comparable within one Currency, so
+// callers set Currency with them. CreatedFrom is inclusive and CreatedTo
+// exclusive. Size orders are returned per page; After is the Next cursor
+// of the previous page. Facets lists the breakdowns to compute;
+// AmountInterval is the width of an amount bucket and CreatedInterval
+// ("day", "week" or "month") the length of a created bucket.
+type OrderQuery struct {
+	Term        string
+	Product     string
+	Currency    string
+	MinAmount   *models.Money
+	MaxAmount   *models.Money
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Size        int
+	After       *Cursor
+
+	Facets          []Facet
+	AmountInterval  models.Money
+	CreatedInterval string
+}
+
+// Cursor is a search_after position in the ranking: relevance first, then
+// newest first, with the order ID breaking ties.
+type Cursor struct {
+	Score     float64
+	CreatedAt int64 // epoch milliseconds, as ES reports a date sort value
+	ID        string
+}
+
+// SearchResult is one page of an order search. Total counts every match,
+// not just this page. Next is nil on the last page, and Facets is nil
+// unless the query asked for some.
+type SearchResult struct {
+	Total  int
+	Orders []models.Order
+	Next   *Cursor
+	Facets *Facets
+}
+
+// SearchOrders runs q against the read alias and returns one page of
+// matching orders.
+func (c *Client) SearchOrders(ctx context.Context, q OrderQuery) (*SearchResult, error) {
+	body := map[string]any{
+		"query": q.query(),
+		"sort": []any{
+			map[string]any{"_score": "desc"},
+			map[string]any{"created_at": "desc"},
+			map[string]any{"id": "asc"},
 		},
+		"size":             q.Size + 1, // one extra hit tells whether another page exists
+		"track_total_hits": true,
+	}
+	if q.After != nil {
+		body["search_after"] = []any{q.After.Score, q.After.CreatedAt, q.After.ID}
+	}
+	if len(q.Facets) > 0 {
+		body["aggs"] = q.aggregations()
 	}
 
 	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
 		return nil, err
 	}
 
 	res, err := c.es.Search(
 		c.es.Search.WithContext(ctx),
-		c.es.Search.WithIndex(ordersIndex),
+		c.es.Search.WithIndex(ordersReadAlias),
 		c.es.Search.WithBody(&buf),
-		c.es.Search.WithTrackTotalHits(true),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("search: query request: %w", err)
@@ -103,5 +252,114 @@ func (c *Client) SearchOrders(ctx context.Context, term string) (json.RawMessage
 		return nil, fmt.Errorf("search: query error [%s]: %s", res.Status(), body)
 	}
 
-	return io.ReadAll(res.Body)
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source models.Order `json:"_source"`
+				Sort   []any        `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]json.RawMessage `json:"aggregations"`
+	}
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber() // keep sort values exact for search_after
+	if err := dec.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("search: decode query response: %w", err)
+	}
+
+	hits := parsed.Hits.Hits
+	result := &SearchResult{Total: parsed.Hits.Total.Value, Orders: make([]models.Order, 0, len(hits))}
+	if len(hits) > q.Size {
+		hits = hits[:q.Size]
+		if result.Next, err = parseCursor(hits[len(hits)-1].Sort); err != nil {
+			return nil, err
+		}
+	}
+	for _, hit := range hits {
+		result.Orders = append(result.Orders, hit.Source)
+	}
+	if len(q.Facets) > 0 {
+		if result.Facets, err = q.parseFacets(parsed.Aggregations); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// query builds the ES query for q: the term as a scoring clause, everything
+// else as filters.
+func (q OrderQuery) query() map[string]any {
+	must, filter := []any{}, []any{}
+	if q.Term != "" {
+		must = append(must, map[string]any{
+			"nested": map[string]any{
+				"path": "items",
+				"query": map[string]any{
+					"multi_match": map[string]any{
+						"query":  q.Term,
+						"fields": []string{"items.product_name", "items.sku"},
+					},
+				},
+				"score_mode": "max",
+			},
+		})
+	}
+	if q.Product != "" {
+		filter = append(filter, map[string]any{
+			"nested": map[string]any{
+				"path":  "items",
+				"query": map[string]any{"term": map[string]any{"items.product_name.keyword": q.Product}},
+			},
+		})
+	}
+	if q.Currency != "" {
+		filter = append(filter, map[string]any{"term": map[string]any{"currency": q.Currency}})
+	}
+	if q.MinAmount != nil || q.MaxAmount != nil {
+		bounds := map[string]any{}
+		if q.MinAmount != nil {
+			bounds["gte"] = *q.MinAmount
+		}
+		if q.MaxAmount != nil {
+			bounds["lte"] = *q.MaxAmount
+		}
+		filter = append(filter, map[string]any{"range": map[string]any{"amount": bounds}})
+	}
+	if !q.CreatedFrom.IsZero() || !q.CreatedTo.IsZero() {
+		bounds := map[string]any{}
+		if !q.CreatedFrom.IsZero() {
+			bounds["gte"] = q.CreatedFrom
+		}
+		if !q.CreatedTo.IsZero() {
+			bounds["lt"] = q.CreatedTo
+		}
+		filter = append(filter, map[string]any{"range": map[string]any{"created_at": bounds}})
+	}
+
+	if len(must) == 0 {
+		must = append(must, map[string]any{"match_all": map[string]any{}})
+	}
+	return map[string]any{"bool": map[string]any{"must": must, "filter": filter}}
+}
+
+// parseCursor reads the sort values of a hit sorted by SearchOrders.
+func parseCursor(sort []any) (*Cursor, error) {
+	if len(sort) == 3 {
+		score, serr := numberValue(sort[0]).Float64()
+		createdAt, cerr := numberValue(sort[1]).Int64()
+		id, ok := sort[2].(string)
+		if serr == nil && cerr == nil && ok {
+			return &Cursor{Score: score, CreatedAt: createdAt, ID: id}, nil
+		}
+	}
+	return nil, fmt.Errorf("search: unexpected sort values %v", sort)
+}
+
+// numberValue returns v as a json.Number, or an invalid one if it is not a number.
+func numberValue(v any) json.Number {
+	n, _ := v.(json.Number)
+	return n
 }
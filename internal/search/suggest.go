@@ -0,0 +1,121 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ProductSuggestion is a product name completing a typed prefix, with the
+// number of orders containing it.
+type ProductSuggestion struct {
+	Product string `json:"product"`
+	Orders  int    `json:"orders"`
+}
+
+// SuggestProducts returns up to limit distinct product names with a word
+// starting with each word of prefix, most ordered first. "gam lap" suggests
+// "Gaming Laptop". Names differing only in case or accents are one
+// suggestion, spelled the way most items spell it. Only the prefix and
+// folded sub-fields of items.product_name are used, so an index created
+// before they were mapped suggests nothing until it is reindexed.
+func (c *Client) SuggestProducts(ctx context.Context, prefix string, limit int) ([]ProductSuggestion, error) {
+	match := map[string]any{
+		"match": map[string]any{
+			"items.product_name.prefix": map[string]any{"query": prefix, "operator": "and"},
+		},
+	}
+
+	// The top-level query picks the orders with a matching item; inside the
+	// nested aggregation the same match keeps only those items, so another
+	// product on the same order is not suggested. Names are grouped by their
+	// folded form; reverse_nested counts the orders per group and ranks the
+	// groups by it, and the top keyword in each is the spelling shown.
+	body := map[string]any{
+		"size":             0,
+		"track_total_hits": false,
+		"query":            map[string]any{"nested": map[string]any{"path": "items", "query": match}},
+		"aggs": map[string]any{
+			"items": map[string]any{
+				"nested": map[string]any{"path": "items"},
+				"aggs": map[string]any{
+					"matching": map[string]any{
+						"filter": match,
+						"aggs": map[string]any{
+							"names": map[string]any{
+								"terms": map[string]any{
+									"field": "items.product_name.folded",
+									"size":  limit,
+									"order": map[string]any{"orders": "desc"},
+								},
+								"aggs": map[string]any{
+									"orders": map[string]any{"reverse_nested": map[string]any{}},
+									"spelling": map[string]any{
+										"terms": map[string]any{"field": "items.product_name.keyword", "size": 1},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, err
+	}
+
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(ordersReadAlias),
+		c.es.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search: suggest request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("search: suggest error [%s]: %s", res.Status(), body)
+	}
+
+	var parsed struct {
+		Aggregations struct {
+			Items struct {
+				Matching struct {
+					Names struct {
+						Buckets []struct {
+							Key    string `json:"key"`
+							Orders struct {
+								DocCount int `json:"doc_count"`
+							} `json:"orders"`
+							Spelling struct {
+								Buckets []struct {
+									Key string `json:"key"`
+								} `json:"buckets"`
+							} `json:"spelling"`
+						} `json:"buckets"`
+					} `json:"names"`
+				} `json:"matching"`
+			} `json:"items"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("search: decode suggest response: %w", err)
+	}
+
+	suggestions := []ProductSuggestion{}
+	for _, b := range parsed.Aggregations.Items.Matching.Names.Buckets {
+		product := b.Key
+		if len(b.Spelling.Buckets) > 0 {
+			product = b.Spelling.Buckets[0].Key
+		}
+		suggestions = append(suggestions, ProductSuggestion{Product: product, Orders: b.Orders.DocCount})
+	}
+	return suggestions, nil
+}
@@ -6,38 +6,121 @@ import (
 	"time"
 
 	"go-polyglot-persistence/internal/database"
-
-	"github.com/robfig/cron/v3"
+	"go-polyglot-persistence/internal/metrics"
+	"go-polyglot-persistence/internal/search"
 )
 
-// StartCronJobs registers the materialized view refresh on the given schedule
-// and starts the scheduler. Returns an error if the schedule string is invalid
-// so that main() can fail fast with a clear message instead of a buried panic.
-//
-// The returned *cron.Cron must be stopped on shutdown:
-//
-//	c, err := StartCronJobs(db, cfg.MVRefreshSchedule)
-//	defer c.Stop()  // waits for any running job to finish before returning
-func StartCronJobs(db *database.DB, schedule string) (*cron.Cron, error) {
-	c := cron.New()
-
-	_, err := c.AddFunc(schedule, func() {
-		slog.Info("mv refresh started", "component", "cron")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
-
-		if err := db.RefreshMaterializedView(ctx); err != nil {
-			slog.Error("mv refresh failed", "component", "cron", "error", err)
-		} else {
-			slog.Info("mv refresh done", "component", "cron")
-		}
-	})
+// The jobs the API registers with its Scheduler. Each constructor takes its
+// schedule and timeout from config, so operators can tune them per job.
+
+// MVRefreshJobName is the name MVRefreshJob registers under, for callers
+// that trigger a refresh.
+const MVRefreshJobName = "mv_refresh"
+
+// MVRefreshJob refreshes the dashboard materialized views.
+func MVRefreshJob(db *database.DB, schedule string, timeout time.Duration) Job {
+	return Job{
+		Name:     MVRefreshJobName,
+		Schedule: schedule,
+		Timeout:  timeout,
+		Run:      db.RefreshMaterializedViews,
+	}
+}
+
+// SalesReconcileJob checks the incremental sales rollup against orders and
+// repairs drift. It also runs whenever this replica becomes leader, so a
+// rollup left behind by "refresh" mode is caught up without waiting for
+// the schedule.
+func SalesReconcileJob(db *database.DB, schedule string, timeout time.Duration) Job {
+	return Job{
+		Name:          "sales_reconcile",
+		Schedule:      schedule,
+		Timeout:       timeout,
+		RunOnElection: true,
+		Run:           func(ctx context.Context) error { return reconcileSalesRollup(ctx, db) },
+	}
+}
+
+// SearchReconcileJob compares the orders index with Postgres, re-indexes
+// orders whose document is missing or stale and deletes documents whose
+// order is gone. The worker only indexes what it consumes, so this is what
+// notices a lost write or a lost index.
+func SearchReconcileJob(db *database.DB, s *search.Client, schedule string, timeout time.Duration) Job {
+	return Job{
+		Name:     "search_reconcile",
+		Schedule: schedule,
+		Timeout:  timeout,
+		Run:      func(ctx context.Context) error { return reconcileSearch(ctx, db, s) },
+	}
+}
+
+// RetentionJob deletes outbox rows published more than outboxAge ago and
+// job runs that started more than runAge ago. Neither table is read after
+// that: the outbox only matters until a row is sent, and job runs are
+// history for operators.
+func RetentionJob(db *database.DB, schedule string, timeout, outboxAge, runAge time.Duration) Job {
+	return Job{
+		Name:     "retention",
+		Schedule: schedule,
+		Timeout:  timeout,
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			outbox, err := db.DeleteSentOutboxBefore(ctx, now.Add(-outboxAge))
+			if err != nil {
+				return err
+			}
+			runs, err := db.DeleteJobRunsBefore(ctx, now.Add(-runAge))
+			if err != nil {
+				return err
+			}
+			slog.Info("retention cleanup done", "component", "cron",
+				"outbox_rows_deleted", outbox,
+				"job_runs_deleted", runs,
+			)
+			return nil
+		},
+	}
+}
+
+// reconcileSalesRollup runs one rollup reconciliation and publishes the
+// drift it found. Drift is repaired by the same call, so it is logged as a
+// warning: it points at an insert path that bypassed the rollup.
+func reconcileSalesRollup(ctx context.Context, db *database.DB) error {
+	drift, err := db.ReconcileSalesRollup(ctx)
+	metrics.SalesRollupDrift.WithLabelValues("missing").Set(float64(drift.Missing))
+	metrics.SalesRollupDrift.WithLabelValues("stale").Set(float64(drift.Stale))
+	metrics.SalesRollupDrift.WithLabelValues("orphaned").Set(float64(drift.Orphaned))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	c.Start()
-	slog.Info("cron scheduler started", "component", "cron", "schedule", schedule)
-	return c, nil
+	if drift.Total() > 0 {
+		slog.Warn("sales rollup drift repaired", "component", "cron",
+			"missing", drift.Missing,
+			"stale", drift.Stale,
+			"orphaned", drift.Orphaned,
+		)
+	}
+	return nil
+}
+
+// reconcileSearch runs one search reconciliation and publishes the drift it
+// found. Like the rollup's, drift is already repaired and logged as a warning.
+func reconcileSearch(ctx context.Context, db *database.DB, s *search.Client) error {
+	drift, err := syncSearch(ctx, db, s)
+	metrics.SearchDrift.WithLabelValues("missing").Set(float64(drift.missing))
+	metrics.SearchDrift.WithLabelValues("stale").Set(float64(drift.stale))
+	metrics.SearchDrift.WithLabelValues("orphaned").Set(float64(drift.orphaned))
+	if err != nil {
+		return err
+	}
+
+	if drift.total() > 0 {
+		slog.Warn("search index drift repaired", "component", "cron",
+			"missing", drift.missing,
+			"stale", drift.stale,
+			"orphaned", drift.orphaned,
+		)
+	}
+	return nil
 }
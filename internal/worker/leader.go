@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/metrics"
+)
+
+// Leader election tuning. cronLeaderKey is the Postgres advisory lock the
+// replicas compete for; it must differ from the migrations' lock key.
+// leaderCheckInterval bounds how long the cluster goes without a leader
+// after the holder's session dies.
+const (
+	cronLeaderKey       int64 = 7_311_042_816
+	leaderCheckInterval       = 5 * time.Second
+)
+
+// LeaderElector elects one API replica to run the cron jobs. Leadership is a
+// Postgres advisory lock held on a dedicated session: followers retry every
+// leaderCheckInterval, and the leader re-checks that it still holds the
+// lock just as often.
+//
+// A leader that loses its session can still be finishing a job when the
+// next leader starts one. The jobs tolerate that: concurrent refreshes of a
+// view serialise on the view's lock, and reconciliation repairs fail rather
+// than overwrite each other.
+type LeaderElector struct {
+	db        *database.DB
+	replica   string
+	leader    atomic.Bool
+	onElected []func()
+	done      chan struct{}
+}
+
+// NewLeaderElector constructs an elector for the named replica. Call Run to start it.
+func NewLeaderElector(db *database.DB, replica string) *LeaderElector {
+	metrics.CronLeader.WithLabelValues(replica).Set(0)
+	return &LeaderElector{db: db, replica: replica, done: make(chan struct{})}
+}
+
+// OnElected registers fn to run each time this replica becomes leader. It
+// must be called before Run. fn runs on the election goroutine, so it must
+// not block: hand real work to a goroutine of its own.
+func (l *LeaderElector) OnElected(fn func()) {
+	l.onElected = append(l.onElected, fn)
+}
+
+// IsLeader reports whether this replica currently holds leadership.
+func (l *LeaderElector) IsLeader() bool { return l.leader.Load() }
+
+// Run campaigns for leadership until ctx is cancelled, then steps down so
+// another replica can take over without waiting for this session to time out.
+func (l *LeaderElector) Run(ctx context.Context) {
+	defer close(l.done)
+
+	slog.Info("leader election started", "component", "leader", "replica", l.replica)
+
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+
+	var lease *database.Leadership
+	for {
+		if lease == nil {
+			var err error
+			if lease, err = l.db.TryLeadership(ctx, cronLeaderKey); err != nil && ctx.Err() == nil {
+				slog.Error("leader election failed", "component", "leader", "replica", l.replica, "error", err)
+			}
+			if lease != nil {
+				l.setLeader(true)
+			}
+		} else if err := lease.Check(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("leadership check failed", "component", "leader", "replica", l.replica, "error", err)
+			lease.Release()
+			lease = nil
+			l.setLeader(false)
+		}
+
+		select {
+		case <-ctx.Done():
+			if lease != nil {
+				lease.Release()
+				l.setLeader(false)
+			}
+			slog.Info("leader election stopped", "component", "leader", "replica", l.replica)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setLeader records a leadership change in the logs and metrics, and runs
+// the OnElected hooks when leadership is gained.
+func (l *LeaderElector) setLeader(leader bool) {
+	l.leader.Store(leader)
+
+	event, gauge := "lost", 0.0
+	if leader {
+		event, gauge = "acquired", 1
+	}
+	metrics.CronLeader.WithLabelValues(l.replica).Set(gauge)
+	metrics.CronLeadershipChanges.WithLabelValues(event).Inc()
+	slog.Info("leadership "+event, "component", "leader", "replica", l.replica)
+
+	if leader {
+		for _, fn := range l.onElected {
+			fn()
+		}
+	}
+}
+
+// Done is closed once Run has returned and leadership, if held, is released.
+func (l *LeaderElector) Done() <-chan struct{} { return l.done }
@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/models"
+	"go-polyglot-persistence/internal/queue"
+)
+
+// Outbox relay tuning.
+// The poll interval bounds the extra latency outbox mode adds to an order;
+// the batch size bounds how long a single relay transaction holds row locks.
+const (
+	outboxPollInterval = time.Second
+	outboxBatchSize    = 100
+)
+
+// OutboxRelay moves committed orders from the Postgres outbox table to RabbitMQ.
+// It runs inside the API process, next to the Publisher it sends through.
+type OutboxRelay struct {
+	db          *database.DB
+	publisher   *queue.Publisher
+	maxAttempts int
+	done        chan struct{}
+}
+
+// NewOutboxRelay constructs a relay. A row is parked once it has failed to
+// publish maxAttempts times; values below 1 are treated as 1. Call Run to
+// start it.
+func NewOutboxRelay(db *database.DB, p *queue.Publisher, maxAttempts int) *OutboxRelay {
+	return &OutboxRelay{db: db, publisher: p, maxAttempts: max(maxAttempts, 1), done: make(chan struct{})}
+}
+
+// Run polls the outbox until ctx is cancelled. A full batch is followed
+// immediately by another pass so a backlog drains without waiting a tick.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	defer close(r.done)
+
+	slog.Info("outbox relay started", "component", "outbox", "interval", outboxPollInterval)
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("outbox relay stopped", "component", "outbox")
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := r.db.RelayOutbox(ctx, outboxBatchSize, r.maxAttempts, r.publish)
+			if err != nil {
+				slog.Error("outbox relay failed", "component", "outbox", "sent", n, "error", err)
+			}
+			if n > 0 {
+				slog.Info("outbox relayed", "component", "outbox", "sent", n)
+			}
+			if n < outboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// publish sends one outbox order. Only a message the broker refused counts
+// against the row; any other failure means the broker is unavailable, and
+// would fail every row alike.
+func (r *OutboxRelay) publish(ctx context.Context, o *models.Order) error {
+	err := r.publisher.PublishOrder(ctx, o)
+	if err != nil && !errors.Is(err, queue.ErrUnroutable) && !errors.Is(err, queue.ErrNacked) {
+		return fmt.Errorf("%w: %w", database.ErrBrokerUnavailable, err)
+	}
+	return err
+}
+
+// Done is closed once Run has returned, so shutdown can wait for an
+// in-flight batch to commit before the publisher and DB are closed.
+func (r *OutboxRelay) Done() <-chan struct{} { return r.done }
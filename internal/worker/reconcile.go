@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/search"
+)
+
+// Search reconciliation tuning. searchReconcileGrace skips orders changed
+// that recently: the worker may simply not have indexed them yet, and
+// counting them would report queue lag as drift.
+const (
+	searchReconcileBatch = 500
+	searchReconcileGrace = 5 * time.Minute
+)
+
+// searchDrift counts the documents one search reconciliation found out of
+// step with Postgres, by kind. All of them have been repaired.
+type searchDrift struct {
+	missing  int // order without a document
+	stale    int // document older than its order
+	orphaned int // document without an order
+}
+
+func (d searchDrift) total() int { return d.missing + d.stale + d.orphaned }
+
+// syncSearch brings the orders index in line with Postgres in two keyset
+// walks. The first goes through orders by ID and re-indexes every order
+// whose document is missing or stale. The second goes through the documents
+// by ID and deletes those whose order does not exist.
+func syncSearch(ctx context.Context, db *database.DB, s *search.Client) (searchDrift, error) {
+	var drift searchDrift
+	before := time.Now().Add(-searchReconcileGrace)
+
+	after := ""
+	for {
+		versions, err := db.ListOrderVersions(ctx, after, before, searchReconcileBatch)
+		if err != nil {
+			return drift, err
+		}
+		if len(versions) == 0 {
+			break
+		}
+
+		updated := make(map[string]time.Time, len(versions))
+		for _, v := range versions {
+			updated[v.ID] = v.UpdatedAt
+		}
+		missing, stale, err := s.CompareOrders(ctx, updated)
+		if err != nil {
+			return drift, err
+		}
+		drift.missing += len(missing)
+		drift.stale += len(stale)
+		if err := reindexOrders(ctx, db, s, append(missing, stale...)); err != nil {
+			return drift, err
+		}
+
+		if len(versions) < searchReconcileBatch {
+			break
+		}
+		after = versions[len(versions)-1].ID
+	}
+
+	after = ""
+	for {
+		ids, err := s.OrderIDsAfter(ctx, after, searchReconcileBatch)
+		if err != nil {
+			return drift, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		exists, err := db.ExistingOrderIDs(ctx, ids)
+		if err != nil {
+			return drift, err
+		}
+		var orphaned []string
+		for _, id := range ids {
+			if !exists[id] {
+				orphaned = append(orphaned, id)
+			}
+		}
+		drift.orphaned += len(orphaned)
+		if err := s.DeleteOrders(ctx, orphaned); err != nil {
+			return drift, err
+		}
+
+		if len(ids) < searchReconcileBatch {
+			break
+		}
+		after = ids[len(ids)-1]
+	}
+	return drift, nil
+}
+
+// reindexOrders re-reads the given orders from Postgres and indexes them.
+// The external version keeps a newer document the worker wrote meanwhile.
+func reindexOrders(ctx context.Context, db *database.DB, s *search.Client, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	orders, err := db.GetOrdersByID(ctx, ids)
+	if err != nil {
+		return err
+	}
+	itemErrs, err := s.IndexOrders(ctx, orders)
+	if err != nil {
+		return err
+	}
+	for i, err := range itemErrs {
+		if err != nil {
+			return fmt.Errorf("reindex order %s: %w", orders[i].ID, err)
+		}
+	}
+	return nil
+}
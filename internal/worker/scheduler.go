@@ -0,0 +1,235 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrUnknownJob is returned by Scheduler.Trigger for a name nobody registered.
+var ErrUnknownJob = errors.New("worker: unknown job")
+
+// Job is a unit of background work the Scheduler runs on a cron schedule,
+// on demand, or both.
+type Job struct {
+	Name string
+
+	// Schedule is a cron spec ("@hourly", "0 3 * * *"). Empty means the job
+	// only runs when triggered.
+	Schedule string
+
+	// Timeout bounds one run. It is also how long a run may stay 'running'
+	// in job_runs before another replica treats it as abandoned.
+	Timeout time.Duration
+
+	// RunOnElection also runs the job each time this replica becomes cron
+	// leader, for jobs that catch up on work missed while nobody led.
+	RunOnElection bool
+
+	Run func(ctx context.Context) error
+}
+
+// JobStatus describes a registered job and its most recent runs, newest first.
+type JobStatus struct {
+	Name     string            `json:"name"`
+	Schedule string            `json:"schedule,omitempty"`
+	Timeout  string            `json:"timeout"`
+	Runs     []database.JobRun `json:"runs"`
+}
+
+// jobHistory is how many recent runs Jobs reports per job.
+const jobHistory = 10
+
+// Scheduler is the registry of background jobs. Every API replica schedules
+// every job, but scheduled and election runs only happen on the cron
+// leader. Every run, however triggered, is recorded in job_runs, whose
+// unique index keeps a job from overlapping itself across replicas.
+type Scheduler struct {
+	db      *database.DB
+	leader  *LeaderElector
+	replica string
+	cron    *cron.Cron
+
+	mu   sync.Mutex
+	jobs []*Job // registration order
+
+	runs sync.WaitGroup // runs started by Trigger or an election
+}
+
+// NewScheduler constructs an empty scheduler. Register jobs, then call Start.
+func NewScheduler(db *database.DB, leader *LeaderElector, replica string) *Scheduler {
+	return &Scheduler{db: db, leader: leader, replica: replica, cron: cron.New()}
+}
+
+// Register adds a job. It fails on a duplicate name, a missing timeout or an
+// invalid schedule, so main() can exit with a clear message. Jobs with
+// RunOnElection must be registered before the elector's Run starts.
+func (s *Scheduler) Register(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(job.Name) != nil {
+		return fmt.Errorf("job %q registered twice", job.Name)
+	}
+	if job.Timeout <= 0 {
+		return fmt.Errorf("job %q: timeout must be positive", job.Name)
+	}
+	j := &job
+	if j.Schedule != "" {
+		if _, err := s.cron.AddFunc(j.Schedule, func() { s.runAsLeader(j, database.TriggerSchedule) }); err != nil {
+			return fmt.Errorf("job %q: invalid schedule %q: %w", j.Name, j.Schedule, err)
+		}
+	}
+	if j.RunOnElection {
+		s.leader.OnElected(func() {
+			s.runs.Add(1)
+			go func() {
+				defer s.runs.Done()
+				s.runAsLeader(j, database.TriggerElection)
+			}()
+		})
+	}
+	s.jobs = append(s.jobs, j)
+	return nil
+}
+
+// Start starts the cron scheduler.
+func (s *Scheduler) Start() {
+	s.cron.Start()
+	for _, j := range s.jobs {
+		slog.Info("job registered", "component", "cron",
+			"job", j.Name,
+			"schedule", j.Schedule,
+			"timeout", j.Timeout,
+		)
+	}
+}
+
+// Stop stops scheduling and waits for running jobs, however triggered, to
+// finish, so the DB pool is not closed under them. Stop the leader elector
+// first so no election starts a run while Stop waits.
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+	s.runs.Wait()
+}
+
+// Trigger starts a run of the named job now, on this replica, whether or
+// not it leads, and returns the recorded run without waiting for it. If the
+// job is already running anywhere it returns that run and
+// database.ErrJobRunning.
+func (s *Scheduler) Trigger(name string) (*database.JobRun, error) {
+	s.mu.Lock()
+	j := s.find(name)
+	s.mu.Unlock()
+	if j == nil {
+		return nil, ErrUnknownJob
+	}
+
+	run, err := s.db.StartJobRun(context.Background(), j.Name, database.TriggerManual, s.replica, j.Timeout)
+	if err != nil {
+		return run, err
+	}
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.execute(j, run)
+	}()
+	return run, nil
+}
+
+// Jobs lists the registered jobs with their recent runs.
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobStatus, error) {
+	runs, err := s.db.RecentJobRuns(ctx, jobHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = JobStatus{
+			Name:     j.Name,
+			Schedule: j.Schedule,
+			Timeout:  j.Timeout.String(),
+			Runs:     runs[j.Name],
+		}
+		if statuses[i].Runs == nil {
+			statuses[i].Runs = []database.JobRun{}
+		}
+	}
+	return statuses, nil
+}
+
+func (s *Scheduler) find(name string) *Job {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// runAsLeader runs j in the calling goroutine if this replica leads and the
+// job is not already running elsewhere.
+func (s *Scheduler) runAsLeader(j *Job, trigger string) {
+	if !s.leader.IsLeader() {
+		slog.Debug("job skipped on follower", "component", "cron", "job", j.Name)
+		return
+	}
+
+	run, err := s.db.StartJobRun(context.Background(), j.Name, trigger, s.replica, j.Timeout)
+	switch {
+	case errors.Is(err, database.ErrJobRunning):
+		slog.Info("job skipped, already running", "component", "cron", "job", j.Name, "trigger", trigger)
+		return
+	case err != nil:
+		slog.Error("job run not recorded, skipped", "component", "cron", "job", j.Name, "error", err)
+		return
+	}
+	s.execute(j, run)
+}
+
+// execute runs j within its timeout and records the outcome of run.
+func (s *Scheduler) execute(j *Job, run *database.JobRun) {
+	slog.Info("job started", "component", "cron", "job", j.Name, "run_id", run.ID, "trigger", run.Trigger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), j.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := j.Run(ctx)
+	status := database.JobSucceeded
+	if err != nil {
+		status = database.JobFailed
+	}
+	metrics.JobRunDuration.With(prometheus.Labels{"job": j.Name, "status": status}).
+		Observe(time.Since(start).Seconds())
+
+	// Record the outcome even if the job used up its whole timeout.
+	if ferr := s.db.FinishJobRun(context.Background(), run.ID, err); ferr != nil {
+		slog.Error("job outcome not recorded", "component", "cron", "job", j.Name, "run_id", run.ID, "error", ferr)
+	}
+
+	if err != nil {
+		slog.Error("job failed", "component", "cron",
+			"job", j.Name,
+			"run_id", run.ID,
+			"duration", time.Since(start),
+			"error", err,
+		)
+		return
+	}
+	slog.Info("job done", "component", "cron", "job", j.Name, "run_id", run.ID, "duration", time.Since(start))
+}
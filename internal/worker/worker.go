@@ -2,98 +2,290 @@ package worker
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log/slog"
+	"sync"
 	"time"
 
+	"go-polyglot-persistence/internal/cache"
 	"go-polyglot-persistence/internal/database"
+	"go-polyglot-persistence/internal/models"
 	"go-polyglot-persistence/internal/queue"
 	"go-polyglot-persistence/internal/search"
 )
 
-// perMessageTimeout caps how long a single Postgres + ES write can take.
-// If Postgres holds a lock beyond this, the message is nacked and requeued
-// rather than blocking the goroutine indefinitely.
-const perMessageTimeout = 10 * time.Second
+// perBatchTimeout caps how long a single batch's Postgres + ES writes can take.
+// If Postgres holds a lock beyond this, the batch is nacked and retried
+// after the queue's retry delay rather than blocking the goroutine indefinitely.
+const perBatchTimeout = 10 * time.Second
 
 // Worker consumes orders from RabbitMQ and persists them to Postgres and ES.
+// It also applies status changes, which additionally rewrite the Redis entry
+// so all three stores converge on the new status.
 type Worker struct {
-	db       *database.DB
-	search   *search.Client
-	consumer *queue.Consumer
+	db          *database.DB
+	search      *search.Client
+	cache       *cache.Client
+	consumer    *queue.Consumer
+	concurrency int
+	batchSize   int
+	batchWindow time.Duration
 }
 
-// New constructs a Worker. All dependencies are injected — no globals.
-func New(db *database.DB, s *search.Client, c *queue.Consumer) *Worker {
-	return &Worker{db: db, search: s, consumer: c}
+// New constructs a Worker that processes up to concurrency batches in
+// parallel (minimum 1). Each goroutine flushes its batch once it holds
+// batchSize deliveries or batchWindow has passed since the first one arrived.
+// All dependencies are injected — no globals.
+func New(db *database.DB, s *search.Client, cc *cache.Client, c *queue.Consumer, concurrency, batchSize int, batchWindow time.Duration) *Worker {
+	return &Worker{
+		db:          db,
+		search:      s,
+		cache:       cc,
+		consumer:    c,
+		concurrency: max(concurrency, 1),
+		batchSize:   max(batchSize, 1),
+		batchWindow: batchWindow,
+	}
 }
 
 // Run starts consuming messages and blocks until ctx is cancelled.
-// On cancellation it drains any in-flight message before returning,
-// so the caller's deferred Close() calls happen after the loop is clean.
+// Deliveries are fanned out to w.concurrency goroutines; each one owns the
+// deliveries it receives and acks or nacks them itself. On cancellation Run
+// waits for every in-flight batch to finish before returning, so the
+// caller's Close() calls happen after all goroutines are clean.
 func (w *Worker) Run(ctx context.Context) error {
 	deliveries, err := w.consumer.Consume()
 	if err != nil {
 		return err
 	}
 
-	slog.Info("worker started", "component", "worker")
+	slog.Info("worker started",
+		"component", "worker",
+		"concurrency", w.concurrency,
+		"batch_size", w.batchSize,
+		"batch_window", w.batchWindow,
+	)
+
+	var wg sync.WaitGroup
+	for i := range w.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, i, deliveries)
+		}()
+	}
+	wg.Wait()
+
+	slog.Info("worker drained", "component", "worker")
+	return nil
+}
+
+// loop is the body of one processing goroutine. It accumulates deliveries
+// into a batch and only checks ctx between batches; on shutdown the partial
+// batch is flushed, so a message that has been received is always processed.
+// Prefetched messages that were never received are redelivered by RabbitMQ
+// once the consumer channel closes.
+func (w *Worker) loop(ctx context.Context, id int, deliveries <-chan queue.Delivery) {
+	batch := make([]queue.Delivery, 0, w.batchSize)
+
+	window := time.NewTimer(w.batchWindow)
+	window.Stop()
+	defer window.Stop()
+
+	flush := func() {
+		window.Stop()
+		if len(batch) > 0 {
+			w.processBatch(batch)
+			batch = batch[:0]
+		}
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("worker shutting down", "component", "worker")
-			return nil
+			flush()
+			slog.Info("worker shutting down", "component", "worker", "goroutine", id)
+			return
 
 		case delivery, ok := <-deliveries:
 			if !ok {
-				slog.Warn("delivery channel closed", "component", "worker")
-				return nil
+				flush()
+				slog.Warn("delivery channel closed", "component", "worker", "goroutine", id)
+				return
 			}
-			w.process(delivery)
+			if delivery.StatusChange != nil {
+				// Flush first: the change may target an order in this very batch.
+				flush()
+				w.processStatusChange(delivery)
+				continue
+			}
+			batch = append(batch, delivery)
+			if len(batch) == 1 {
+				window.Reset(w.batchWindow)
+			}
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+
+		case <-window.C:
+			flush()
 		}
 	}
 }
 
-// process handles a single delivery: write to Postgres, index in ES, then ack.
-// Each step gets its own timeout so a lock or slow ES node cannot block forever.
-func (w *Worker) process(d queue.Delivery) {
-	order := d.Order
-
-	ctx, cancel := context.WithTimeout(context.Background(), perMessageTimeout)
+// processBatch writes a batch to Postgres, indexes it in ES, then acks or
+// nacks every delivery individually. The batch shares one timeout so a lock
+// or slow ES node cannot block forever.
+func (w *Worker) processBatch(batch []queue.Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), perBatchTimeout)
 	defer cancel()
 
 	// Step 1 — Postgres (source of truth, idempotent via ON CONFLICT DO NOTHING)
-	if err := w.db.InsertOrderIdempotent(ctx, order); err != nil {
-		slog.Error("postgres insert failed",
+	persisted := w.insertBatch(ctx, batch)
+	if len(persisted) == 0 {
+		return
+	}
+
+	// Step 2 — Elasticsearch (search projection, idempotent via document ID upsert)
+	orders := make([]*models.Order, len(persisted))
+	for i, d := range persisted {
+		orders[i] = d.Order
+	}
+
+	itemErrs, err := w.search.IndexOrders(ctx, orders)
+	if err != nil {
+		slog.Error("elasticsearch bulk index failed",
 			"component", "worker",
-			"order_id", order.ID,
+			"batch_size", len(persisted),
 			"error", err,
 		)
-		d.Nack()
+		// Postgres rows exist; ON CONFLICT DO NOTHING handles the replay.
+		for _, d := range persisted {
+			d.Nack()
+		}
 		return
 	}
 
-	// Step 2 — Elasticsearch (search projection, idempotent via document ID upsert)
-	if err := w.search.IndexOrder(ctx, order); err != nil {
-		slog.Error("elasticsearch index failed",
+	// Step 3 — Ack each delivery whose ES write succeeded
+	for i, d := range persisted {
+		if itemErrs[i] != nil {
+			w.fail(d, "elasticsearch index failed", itemErrs[i])
+			continue
+		}
+		if err := d.Ack(); err != nil {
+			slog.Error("ack failed", "component", "worker", "order_id", d.Order.ID, "error", err)
+			continue
+		}
+		slog.Info("order processed",
+			"component", "worker",
+			"order_id", d.Order.ID,
+			"items", len(d.Order.Items),
+		)
+	}
+}
+
+// insertBatch writes the batch in one multi-row statement and returns the
+// deliveries whose rows are now in Postgres. If the statement fails — one
+// poison row fails the whole INSERT — it falls back to per-row inserts so
+// only the offending deliveries are nacked.
+func (w *Worker) insertBatch(ctx context.Context, batch []queue.Delivery) []queue.Delivery {
+	orders := make([]*models.Order, len(batch))
+	for i, d := range batch {
+		orders[i] = d.Order
+	}
+
+	err := w.db.InsertOrdersIdempotent(ctx, orders)
+	if err == nil {
+		return batch
+	}
+	if len(batch) == 1 {
+		w.fail(batch[0], "postgres insert failed", err)
+		return nil
+	}
+
+	slog.Warn("postgres batch insert failed, retrying per row",
+		"component", "worker",
+		"batch_size", len(batch),
+		"error", err,
+	)
+
+	persisted := make([]queue.Delivery, 0, len(batch))
+	for _, d := range batch {
+		if err := w.db.InsertOrderIdempotent(ctx, d.Order); err != nil {
+			w.fail(d, "postgres insert failed", err)
+			continue
+		}
+		persisted = append(persisted, d)
+	}
+	return persisted
+}
+
+// processStatusChange applies one status transition: Postgres first, then
+// re-index in ES, then rewrite the cached copy. Every step is idempotent, so
+// a nack at any point is safe to replay.
+func (w *Worker) processStatusChange(d queue.Delivery) {
+	change := d.StatusChange
+
+	ctx, cancel := context.WithTimeout(context.Background(), perBatchTimeout)
+	defer cancel()
+
+	// Step 1 — Postgres (row-locked, validated transition)
+	order, err := w.db.UpdateOrderStatus(ctx, change.OrderID, change.Status, change.RequestedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// The creation may still be queued or in another goroutine's batch.
+		w.fail(d, "status change for unknown order", err)
+		return
+	case errors.Is(err, database.ErrInvalidTransition):
+		// Retrying cannot help — park it so the rejected request stays visible.
+		slog.Warn("status change rejected",
 			"component", "worker",
-			"order_id", order.ID,
+			"order_id", change.OrderID,
+			"status", change.Status,
 			"error", err,
 		)
-		// Postgres row exists; ON CONFLICT DO NOTHING handles the replay.
-		d.Nack()
+		d.Discard()
+		return
+	case err != nil:
+		w.fail(d, "postgres status update failed", err)
+		return
+	}
+
+	// Step 2 — Elasticsearch (versioned by updated_at, so replays are no-ops)
+	if err := w.search.IndexOrder(ctx, order); err != nil {
+		w.fail(d, "elasticsearch index failed", err)
 		return
 	}
 
-	// Step 3 — Ack: remove from queue only after both writes succeeded
+	// Step 3 — Redis: rewrite, or at least invalidate, so GET cannot serve
+	// the old status for the rest of the TTL.
+	if err := w.cache.SetOrder(ctx, order); err != nil {
+		if delErr := w.cache.DeleteOrder(ctx, order.ID); delErr != nil {
+			w.fail(d, "cache update failed", errors.Join(err, delErr))
+			return
+		}
+	}
+
 	if err := d.Ack(); err != nil {
 		slog.Error("ack failed", "component", "worker", "order_id", order.ID, "error", err)
 		return
 	}
 
-	slog.Info("order processed",
+	slog.Info("order status changed",
 		"component", "worker",
 		"order_id", order.ID,
-		"product", order.ProductName,
+		"status", order.Status,
+	)
+}
+
+// fail logs a per-delivery failure and nacks it into the retry queue.
+func (w *Worker) fail(d queue.Delivery, msg string, err error) {
+	slog.Error(msg,
+		"component", "worker",
+		"order_id", d.OrderID(),
+		"attempt", d.Attempt(),
+		"error", err,
 	)
+	d.Nack()
 }